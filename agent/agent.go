@@ -0,0 +1,273 @@
+// Package agent holds Hawk keys in a separate process and signs for
+// clients over a Unix socket, like ssh-agent, so that the keys never live
+// in the process making the requests.
+//
+// The agent is run with cmd/hawk-agent, which sets HAWK_AGENT_SOCK.
+// Clients sign through it with a hawk.Signer:
+//
+//     ac, err := agent.Dial("")
+//     s, err := ac.Signer("your-hawk-id")
+//     hc := hawk.NewClientWithSigner("your-hawk-id", s, 6)
+//
+// The agent only signs normalized Hawk strings, so it cannot be used to
+// calculate MACs over arbitrary data, and refuses to sign with expired,
+// revoked or not yet valid credentials.
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	hawk "gitlab.com/tdely/go-hawk"
+)
+
+// SocketEnv is the environment variable holding the agent socket path.
+const SocketEnv = "HAWK_AGENT_SOCK"
+
+// Errors returned by the agent.
+var (
+	ErrNoSocket          = errors.New("No agent socket, " + SocketEnv + " not set")
+	ErrUnknownID         = errors.New("Unknown id")
+	ErrNotNormalizedHawk = errors.New("Not a normalized Hawk string")
+	ErrUnknownOperation  = errors.New("Unknown operation")
+)
+
+// normalizedPrefix starts every string the agent signs.
+var normalizedPrefix = []byte("hawk.1.")
+
+// request is sent to the agent, one JSON object per line.
+type request struct {
+	Op   string `json:"op"`
+	ID   string `json:"id,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// response is returned by the agent for every request.
+type response struct {
+	Keys  []Key  `json:"keys,omitempty"`
+	MAC   []byte `json:"mac,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Key describes a key held by the agent.
+type Key struct {
+	ID        string `json:"id"`
+	Algorithm string `json:"algorithm"`
+}
+
+// Agent holds keys and signs with them. Keys are only used for signing
+// while their credentials are valid.
+type Agent struct {
+	// Now returns the time the validity of keys is checked at, time.Now if
+	// nil.
+	Now func() time.Time
+
+	mu   sync.RWMutex
+	keys map[string]hawk.Credentials
+}
+
+// New creates an agent holding creds.
+func New(creds hawk.CredentialMap) *Agent {
+	a := &Agent{keys: map[string]hawk.Credentials{}}
+	for _, c := range creds {
+		a.Add(c)
+	}
+	return a
+}
+
+// Add adds or replaces the key of c.ID.
+func (a *Agent) Add(c hawk.Credentials) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.keys[c.ID] = c
+}
+
+// Remove removes the key of id.
+func (a *Agent) Remove(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.keys, id)
+}
+
+// Serve accepts connections on l until it fails.
+func (a *Agent) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go a.ServeConn(conn)
+	}
+}
+
+// ServeConn answers requests on conn until it is closed.
+func (a *Agent) ServeConn(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	enc := json.NewEncoder(conn)
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		if err := enc.Encode(a.handle(req)); err != nil {
+			return
+		}
+	}
+}
+
+func (a *Agent) handle(req request) response {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	switch req.Op {
+	case "list":
+		resp := response{Keys: []Key{}}
+		for id, c := range a.keys {
+			resp.Keys = append(resp.Keys, Key{ID: id, Algorithm: hawk.AlgorithmName(c.Algorithm)})
+		}
+		return resp
+	case "sign":
+		c, ok := a.keys[req.ID]
+		if !ok {
+			return response{Error: ErrUnknownID.Error()}
+		}
+		now := time.Now
+		if a.Now != nil {
+			now = a.Now
+		}
+		if err := c.Valid(now()); err != nil {
+			return response{Error: err.Error()}
+		}
+		if !bytes.HasPrefix(req.Data, normalizedPrefix) {
+			return response{Error: ErrNotNormalizedHawk.Error()}
+		}
+		mac, err := c.Signer().Sign(req.Data)
+		if err != nil {
+			return response{Error: err.Error()}
+		}
+		return response{MAC: mac}
+	}
+	return response{Error: ErrUnknownOperation.Error()}
+}
+
+// Client talks to an agent.
+type Client struct {
+	path string
+
+	mu   sync.Mutex
+	conn net.Conn
+	dec  *json.Decoder
+	enc  *json.Encoder
+}
+
+// Dial connects to the agent listening on the Unix socket path, or on
+// $HAWK_AGENT_SOCK if path is empty.
+func Dial(path string) (*Client, error) {
+	if path == "" {
+		if path = os.Getenv(SocketEnv); path == "" {
+			return nil, ErrNoSocket
+		}
+	}
+	c := &Client{path: path}
+	if err := c.dial(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) dial() error {
+	conn, err := net.Dial("unix", c.path)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.dec = json.NewDecoder(bufio.NewReader(conn))
+	c.enc = json.NewEncoder(conn)
+	return nil
+}
+
+// Close closes the connection to the agent.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// roundTrip sends req and reads the response, reconnecting if the previous
+// connection was lost.
+func (c *Client) roundTrip(req request) (response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.dial(); err != nil {
+			return response{}, err
+		}
+	}
+	var resp response
+	err := c.enc.Encode(req)
+	if err == nil {
+		err = c.dec.Decode(&resp)
+	}
+	if err != nil {
+		c.conn.Close()
+		c.conn = nil
+		return response{}, err
+	}
+	if resp.Error != "" {
+		return response{}, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// List returns the keys held by the agent.
+func (c *Client) List() ([]Key, error) {
+	resp, err := c.roundTrip(request{Op: "list"})
+	return resp.Keys, err
+}
+
+// Signer returns a hawk.Signer signing with the key of id held by the
+// agent.
+func (c *Client) Signer(id string) (hawk.Signer, error) {
+	keys, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if k.ID == id {
+			alg, err := hawk.LookupAlgorithm(k.Algorithm)
+			if err != nil {
+				return nil, err
+			}
+			return &signer{c: c, id: id, alg: alg}, nil
+		}
+	}
+	return nil, ErrUnknownID
+}
+
+// signer signs through the agent.
+type signer struct {
+	c   *Client
+	id  string
+	alg crypto.Hash
+}
+
+func (s *signer) Algorithm() crypto.Hash {
+	return s.alg
+}
+
+func (s *signer) Sign(normalized []byte) ([]byte, error) {
+	resp, err := s.c.roundTrip(request{Op: "sign", ID: s.id, Data: normalized})
+	return resp.MAC, err
+}
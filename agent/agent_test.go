@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"crypto"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	hawk "gitlab.com/tdely/go-hawk"
+)
+
+var testCreds = hawk.CredentialMap{
+	"jdoe": {ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256},
+}
+
+// startAgent serves a on a Unix socket in a temporary directory and returns
+// the socket path.
+func startAgent(t *testing.T, a *Agent) string {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen failed: %s", err.Error())
+	}
+	t.Cleanup(func() { l.Close() })
+	go a.Serve(l)
+	return path
+}
+
+func dial(t *testing.T, path string) *Client {
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatalf("Dial failed: %s", err.Error())
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestAgent(t *testing.T) {
+	a := New(testCreds)
+	c := dial(t, startAgent(t, a))
+
+	keys, err := c.List()
+	if err != nil {
+		t.Fatalf("List failed: %s", err.Error())
+	}
+	if len(keys) != 1 || keys[0] != (Key{ID: "jdoe", Algorithm: "sha256"}) {
+		t.Errorf("List failed: unexpected keys: %+v", keys)
+	}
+
+	s, err := c.Signer("jdoe")
+	if err != nil {
+		t.Fatalf("Signer failed: %s", err.Error())
+	}
+	hc := hawk.NewClientWithSigner("jdoe", s, 6)
+	req, err := hc.NewRequest("GET", "http://example.com/resource", nil, "", "")
+	if err != nil {
+		t.Fatalf("NewRequest failed: %s", err.Error())
+	}
+	r := httptest.NewRequest(req.Method, req.URL.String(), nil)
+	r.Header = req.Header
+	v := &hawk.Verifier{Credentials: testCreds}
+	if _, _, err := v.Verify(r); err != nil {
+		t.Errorf("Verify failed: %s", err.Error())
+	}
+
+	if _, err := s.Sign([]byte("arbitrary data")); err == nil || err.Error() != ErrNotNormalizedHawk.Error() {
+		t.Errorf("Sign failed:\n  got:  %v\n  want: %v", err, ErrNotNormalizedHawk)
+	}
+	if _, err := c.Signer("unknown"); err != ErrUnknownID {
+		t.Errorf("Signer failed:\n  got:  %v\n  want: %v", err, ErrUnknownID)
+	}
+
+	a.Remove("jdoe")
+	if _, err := hc.NewRequest("GET", "http://example.com/resource", nil, "", ""); err == nil {
+		t.Errorf("NewRequest failed: signed with removed key")
+	}
+}
+
+func TestValidity(t *testing.T) {
+	a := New(hawk.CredentialMap{
+		"expired": {ID: "expired", Key: []byte("old"), Algorithm: crypto.SHA256, NotAfter: time.Unix(1000, 0)},
+		"pending": {ID: "pending", Key: []byte("new"), Algorithm: crypto.SHA256, NotBefore: time.Unix(3000, 0)},
+		"revoked": {ID: "revoked", Key: []byte("bad"), Algorithm: crypto.SHA256, Revoked: true},
+		"jdoe":    {ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256},
+	})
+	a.Now = func() time.Time { return time.Unix(2000, 0) }
+	ac := dial(t, startAgent(t, a))
+
+	cases := []struct {
+		id  string
+		err error
+	}{
+		{"expired", hawk.ErrCredentialsExpired},
+		{"pending", hawk.ErrCredentialsNotYetValid},
+		{"revoked", hawk.ErrCredentialsRevoked},
+		{"jdoe", nil},
+	}
+	for _, c := range cases {
+		s, err := ac.Signer(c.id)
+		if err != nil {
+			t.Fatalf("Signer failed: %s", err.Error())
+		}
+		hc := hawk.NewClientWithSigner(c.id, s, 6)
+		_, err = hc.NewRequest("GET", "http://example.com/resource", nil, "", "")
+		if (err == nil) != (c.err == nil) || (err != nil && err.Error() != c.err.Error()) {
+			t.Errorf("NewRequest %s failed:\n  got:  %v\n  want: %v", c.id, err, c.err)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	a := New(testCreds)
+	path := startAgent(t, a)
+	c := dial(t, path)
+	c.conn.Close()
+	// The first call fails on the lost connection, the next reconnects.
+	c.List()
+	if _, err := c.List(); err != nil {
+		t.Errorf("List failed: %s", err.Error())
+	}
+}
+
+func TestDialEnv(t *testing.T) {
+	t.Setenv(SocketEnv, "")
+	if _, err := Dial(""); err != ErrNoSocket {
+		t.Errorf("Dial failed:\n  got:  %v\n  want: %v", err, ErrNoSocket)
+	}
+	t.Setenv(SocketEnv, startAgent(t, New(testCreds)))
+	c, err := Dial("")
+	if err != nil {
+		t.Fatalf("Dial failed: %s", err.Error())
+	}
+	c.Close()
+}
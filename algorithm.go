@@ -0,0 +1,86 @@
+package hawk
+
+import (
+	"crypto"
+	// Register the hash functions named in the algorithm registry.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"errors"
+	"sync"
+)
+
+// Errors returned when resolving or whitelisting algorithms.
+var (
+	ErrUnknownAlgorithm    = errors.New("Unknown algorithm")
+	ErrAlgorithmNotAllowed = errors.New("Algorithm not allowed")
+)
+
+// DefaultAlgorithms is the whitelist used by a Verifier that has no
+// Algorithms of its own. SHA1 is registered for interoperability with
+// older credentials but is deliberately left out.
+var DefaultAlgorithms = []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512}
+
+var (
+	algorithmsMu sync.RWMutex
+	algorithms   = map[string]crypto.Hash{
+		"sha1":   crypto.SHA1,
+		"sha256": crypto.SHA256,
+		"sha384": crypto.SHA384,
+		"sha512": crypto.SHA512,
+	}
+)
+
+// RegisterAlgorithm makes h available under name, the spelling used for
+// the algorithm in Hawk credentials. The hash function must be linked into
+// the binary.
+func RegisterAlgorithm(name string, h crypto.Hash) error {
+	if !h.Available() {
+		return ErrUnknownAlgorithm
+	}
+	algorithmsMu.Lock()
+	defer algorithmsMu.Unlock()
+	algorithms[name] = h
+	return nil
+}
+
+// LookupAlgorithm returns the hash registered under name, such as "sha256".
+func LookupAlgorithm(name string) (crypto.Hash, error) {
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+	h, ok := algorithms[name]
+	if !ok {
+		return 0, ErrUnknownAlgorithm
+	}
+	return h, nil
+}
+
+// AlgorithmName returns the registered name of h, or an empty string if h
+// is not registered.
+func AlgorithmName(h crypto.Hash) string {
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+	for name, alg := range algorithms {
+		if alg == h {
+			return name
+		}
+	}
+	return ""
+}
+
+// allowedAlgorithm reports whether h is registered and in the whitelist,
+// falling back to DefaultAlgorithms for an empty whitelist.
+func allowedAlgorithm(h crypto.Hash, whitelist []crypto.Hash) error {
+	if AlgorithmName(h) == "" {
+		return ErrUnknownAlgorithm
+	}
+	if len(whitelist) == 0 {
+		whitelist = DefaultAlgorithms
+	}
+	for _, alg := range whitelist {
+		if alg == h {
+			return nil
+		}
+	}
+	return ErrAlgorithmNotAllowed
+}
@@ -0,0 +1,56 @@
+package hawk
+
+import (
+	"crypto"
+	"testing"
+)
+
+func TestAlgorithms(t *testing.T) {
+	t.Run("lookup", func(t *testing.T) {
+		h, err := LookupAlgorithm("sha512")
+		if err != nil {
+			t.Errorf("LookupAlgorithm failed: %s", err.Error())
+		}
+		if got, want := h, crypto.SHA512; got != want {
+			t.Errorf("LookupAlgorithm failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+	t.Run("lookup-unknown", func(t *testing.T) {
+		if _, err := LookupAlgorithm("md5"); err != ErrUnknownAlgorithm {
+			t.Errorf("LookupAlgorithm failed: no error on unknown algorithm")
+		}
+	})
+	t.Run("name", func(t *testing.T) {
+		if got, want := AlgorithmName(crypto.SHA1), "sha1"; got != want {
+			t.Errorf("AlgorithmName failed:\n  got:  %s\n  want: %s", got, want)
+		}
+		if got, want := AlgorithmName(crypto.MD5), ""; got != want {
+			t.Errorf("AlgorithmName failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("whitelist-default", func(t *testing.T) {
+		if err := allowedAlgorithm(crypto.SHA256, nil); err != nil {
+			t.Errorf("allowedAlgorithm failed: %s", err.Error())
+		}
+		if got, want := allowedAlgorithm(crypto.SHA1, nil), ErrAlgorithmNotAllowed; got != want {
+			t.Errorf("allowedAlgorithm failed:\n  got:  %v\n  want: %v", got, want)
+		}
+		if got, want := allowedAlgorithm(crypto.MD5, nil), ErrUnknownAlgorithm; got != want {
+			t.Errorf("allowedAlgorithm failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+	t.Run("whitelist-explicit", func(t *testing.T) {
+		wl := []crypto.Hash{crypto.SHA1}
+		if err := allowedAlgorithm(crypto.SHA1, wl); err != nil {
+			t.Errorf("allowedAlgorithm failed: %s", err.Error())
+		}
+		if got, want := allowedAlgorithm(crypto.SHA256, wl), ErrAlgorithmNotAllowed; got != want {
+			t.Errorf("allowedAlgorithm failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+	t.Run("register-unavailable", func(t *testing.T) {
+		if err := RegisterAlgorithm("md4", crypto.MD4); err != ErrUnknownAlgorithm {
+			t.Errorf("RegisterAlgorithm failed: no error on unavailable hash")
+		}
+	})
+}
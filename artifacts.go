@@ -0,0 +1,206 @@
+package hawk
+
+import (
+	"crypto"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrInvalidArtifacts is returned when unmarshaling malformed Artifacts.
+var ErrInvalidArtifacts = errors.New("Invalid artifacts")
+
+// artifactsVersion is the first byte of the binary form of Artifacts.
+const artifactsVersion = 1
+
+// Artifacts are the details of a signed request that its response is
+// validated against, such as the timestamp and nonce, so that a response
+// can be validated by another process than the one that sent the request.
+// They contain no key.
+//
+// Artifacts marshal to JSON with the algorithm by its registered name:
+//
+//     {"algorithm":"sha256","ts":1353832234,"nonce":"j4h3g2","method":"GET","uri":"/resource/1","host":"example.com","port":"8000","mac":"6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="}
+//
+// and to a compact binary form with MarshalBinary.
+type Artifacts struct {
+	Algorithm crypto.Hash
+	Timestamp int64
+	Nonce     string
+	Method    string
+	URI       string
+	Host      string
+	Port      string
+	// Hash is the payload hash of the request, if any.
+	Hash string
+	Ext  string
+	MAC  string
+	// Encoding is the ContentEncoding of the Client that sent the request.
+	Encoding EncodingMode
+}
+
+// Artifacts returns the artifacts of h.
+func (h *Hawk) Artifacts() Artifacts {
+	return Artifacts{
+		Algorithm: h.algorithm,
+		Timestamp: h.timestamp,
+		Nonce:     h.nonce,
+		Method:    h.method,
+		URI:       h.uri,
+		Host:      h.host,
+		Port:      h.port,
+		Hash:      h.reqHash,
+		Ext:       h.reqExt,
+		MAC:       h.reqMAC,
+		Encoding:  h.encoding}
+}
+
+// Artifacts returns the artifacts of the request last made by c.
+func (c *Client) Artifacts() Artifacts {
+	h, _ := c.last()
+	return h.Artifacts()
+}
+
+// Hawk restores the Hawk of a request from its artifacts, for validating
+// the response with ValidateResponse.
+func (a Artifacts) Hawk() Hawk {
+	return Hawk{
+		algorithm: a.Algorithm,
+		timestamp: a.Timestamp,
+		nonce:     a.Nonce,
+		method:    a.Method,
+		uri:       a.URI,
+		host:      a.Host,
+		port:      a.Port,
+		reqHash:   a.Hash,
+		reqExt:    a.Ext,
+		reqMAC:    a.MAC,
+		encoding:  a.Encoding}
+}
+
+// ValidateResponse validates the response to the request of a, see
+// Hawk.ValidateResponse.
+func (a Artifacts) ValidateResponse(k []byte, r http.Response) bool {
+	h := a.Hawk()
+	return h.ValidateResponse(k, r)
+}
+
+// ValidateResponseSigner is ValidateResponse with the MAC calculated by s.
+func (a Artifacts) ValidateResponseSigner(s Signer, r http.Response) bool {
+	h := a.Hawk()
+	return h.ValidateResponseSigner(s, r)
+}
+
+// artifactsJSON is the JSON form of Artifacts.
+type artifactsJSON struct {
+	Algorithm string       `json:"algorithm"`
+	Timestamp int64        `json:"ts"`
+	Nonce     string       `json:"nonce"`
+	Method    string       `json:"method"`
+	URI       string       `json:"uri"`
+	Host      string       `json:"host"`
+	Port      string       `json:"port"`
+	Hash      string       `json:"hash,omitempty"`
+	Ext       string       `json:"ext,omitempty"`
+	MAC       string       `json:"mac"`
+	Encoding  EncodingMode `json:"encoding,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a Artifacts) MarshalJSON() ([]byte, error) {
+	name := AlgorithmName(a.Algorithm)
+	if name == "" {
+		return nil, ErrUnknownAlgorithm
+	}
+	return json.Marshal(artifactsJSON{name, a.Timestamp, a.Nonce, a.Method, a.URI, a.Host, a.Port, a.Hash, a.Ext, a.MAC, a.Encoding})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *Artifacts) UnmarshalJSON(b []byte) error {
+	var j artifactsJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	alg, err := LookupAlgorithm(j.Algorithm)
+	if err != nil {
+		return err
+	}
+	*a = Artifacts{alg, j.Timestamp, j.Nonce, j.Method, j.URI, j.Host, j.Port, j.Hash, j.Ext, j.MAC, j.Encoding}
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The binary form is a
+// version byte followed by the fields in order, with the algorithm by its
+// registered name, the timestamp and encoding as varints and strings
+// prefixed by their length as uvarints.
+func (a Artifacts) MarshalBinary() ([]byte, error) {
+	name := AlgorithmName(a.Algorithm)
+	if name == "" {
+		return nil, ErrUnknownAlgorithm
+	}
+	b := make([]byte, 0, 64+len(a.Nonce)+len(a.URI)+len(a.Host)+len(a.Hash)+len(a.Ext)+len(a.MAC))
+	b = append(b, artifactsVersion)
+	b = appendString(b, name)
+	b = binary.AppendVarint(b, a.Timestamp)
+	for _, s := range []string{a.Nonce, a.Method, a.URI, a.Host, a.Port, a.Hash, a.Ext, a.MAC} {
+		b = appendString(b, s)
+	}
+	return binary.AppendVarint(b, int64(a.Encoding)), nil
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(binary.AppendUvarint(b, uint64(len(s))), s...)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (a *Artifacts) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 || b[0] != artifactsVersion {
+		return ErrInvalidArtifacts
+	}
+	r := artifactsReader{b: b[1:]}
+	name := r.string()
+	ts := r.varint()
+	var fields [8]string
+	for i := range fields {
+		fields[i] = r.string()
+	}
+	enc := r.varint()
+	if r.err || len(r.b) != 0 {
+		return ErrInvalidArtifacts
+	}
+	alg, err := LookupAlgorithm(name)
+	if err != nil {
+		return err
+	}
+	*a = Artifacts{alg, ts, fields[0], fields[1], fields[2], fields[3], fields[4], fields[5], fields[6], fields[7], EncodingMode(enc)}
+	return nil
+}
+
+// artifactsReader reads the binary form of Artifacts, setting err on
+// malformed input.
+type artifactsReader struct {
+	b   []byte
+	err bool
+}
+
+func (r *artifactsReader) varint() int64 {
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = true
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *artifactsReader) string() string {
+	l, n := binary.Uvarint(r.b)
+	if n <= 0 || l > uint64(len(r.b)-n) {
+		r.err = true
+		return ""
+	}
+	s := string(r.b[n : n+int(l)])
+	r.b = r.b[n+int(l):]
+	return s
+}
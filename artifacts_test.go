@@ -0,0 +1,141 @@
+package hawk
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestArtifactsMarshal(t *testing.T) {
+	a := Artifacts{
+		Algorithm: crypto.SHA256,
+		Timestamp: 1353832234,
+		Nonce:     "j4h3g2",
+		Method:    "POST",
+		URI:       "/resource/1?b=1&a=2",
+		Host:      "example.com",
+		Port:      "8000",
+		Hash:      "Yi9LfIIFRtBEPt74PVmbTF/xVAwPn7ub15ePICfgnuY=",
+		Ext:       "some-app-ext-data",
+		MAC:       "aSe1DERmZuRl3pI36/9BdZmnErTw3sNzOOAUlfeKjVw=",
+		Encoding:  HashDecoded,
+	}
+	t.Run("json", func(t *testing.T) {
+		b, err := json.Marshal(a)
+		if err != nil {
+			t.Fatalf("MarshalJSON failed: %s", err.Error())
+		}
+		want := `{"algorithm":"sha256","ts":1353832234,"nonce":"j4h3g2","method":"POST","uri":"/resource/1?b=1\u0026a=2","host":"example.com","port":"8000","hash":"Yi9LfIIFRtBEPt74PVmbTF/xVAwPn7ub15ePICfgnuY=","ext":"some-app-ext-data","mac":"aSe1DERmZuRl3pI36/9BdZmnErTw3sNzOOAUlfeKjVw=","encoding":2}`
+		if got := string(b); got != want {
+			t.Errorf("MarshalJSON failed:\n  got:  %s\n  want: %s", got, want)
+		}
+		var got Artifacts
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("UnmarshalJSON failed: %s", err.Error())
+		}
+		if got != a {
+			t.Errorf("UnmarshalJSON failed:\n  got:  %+v\n  want: %+v", got, a)
+		}
+		if err := json.Unmarshal([]byte(`{"algorithm":"md5"}`), &got); err != ErrUnknownAlgorithm {
+			t.Errorf("UnmarshalJSON failed:\n  got:  %v\n  want: %v", err, ErrUnknownAlgorithm)
+		}
+	})
+	t.Run("binary", func(t *testing.T) {
+		b, err := a.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary failed: %s", err.Error())
+		}
+		var got Artifacts
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("UnmarshalBinary failed: %s", err.Error())
+		}
+		if got != a {
+			t.Errorf("UnmarshalBinary failed:\n  got:  %+v\n  want: %+v", got, a)
+		}
+		for i := 0; i < len(b); i++ {
+			if err := got.UnmarshalBinary(b[:i]); err != ErrInvalidArtifacts {
+				t.Errorf("UnmarshalBinary of %d bytes failed:\n  got:  %v\n  want: %v", i, err, ErrInvalidArtifacts)
+			}
+		}
+		if err := got.UnmarshalBinary(append(b, 0)); err != ErrInvalidArtifacts {
+			t.Errorf("UnmarshalBinary failed:\n  got:  %v\n  want: %v", err, ErrInvalidArtifacts)
+		}
+	})
+	t.Run("unknown-algorithm", func(t *testing.T) {
+		b := a
+		b.Algorithm = crypto.MD5
+		if _, err := b.MarshalBinary(); err != ErrUnknownAlgorithm {
+			t.Errorf("MarshalBinary failed:\n  got:  %v\n  want: %v", err, ErrUnknownAlgorithm)
+		}
+		if _, err := json.Marshal(b); err == nil {
+			t.Errorf("MarshalJSON failed: no error on unknown algorithm")
+		}
+	})
+}
+
+func TestArtifactsValidateResponse(t *testing.T) {
+	key := []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")
+	v := &Verifier{Credentials: CredentialMap{
+		"dh37fgj492je": {ID: "dh37fgj492je", Key: key, Algorithm: crypto.SHA256},
+	}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, creds, err := v.Verify(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Server-Authorization", h.GetServerAuthorization(creds.Key, "text/plain", []byte("Some reply"), "response-ext"))
+		w.Write([]byte("Some reply"))
+	}))
+	defer ts.Close()
+
+	c := NewClient("dh37fgj492je", key, crypto.SHA256, 6)
+	req, err := c.NewRequest("POST", ts.URL+"/resource/1?b=1&a=2", strings.NewReader("Thank you for flying Hawk"), "text/plain", "some-app-ext-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// The artifacts and response are handed to another process.
+	j, err := json.Marshal(c.Artifacts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, err := c.Artifacts().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON, fromBinary Artifacts
+	if err := json.Unmarshal(j, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := fromBinary.UnmarshalBinary(bin); err != nil {
+		t.Fatal(err)
+	}
+	for name, a := range map[string]Artifacts{"json": fromJSON, "binary": fromBinary} {
+		r := *resp
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if !a.ValidateResponse(key, r) {
+			t.Errorf("ValidateResponse from %s failed", name)
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if a.ValidateResponse([]byte("wrong"), r) {
+			t.Errorf("ValidateResponse from %s failed: valid with wrong key", name)
+		}
+		h := a.Hawk()
+		if got, want := h.GetAuthorization("dh37fgj492je"), req.Header.Get("Authorization"); got != want {
+			t.Errorf("Hawk from %s failed:\n  got:  %s\n  want: %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package hawk
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"fmt"
+	"time"
+)
+
+// Bewit calculates a bewit granting GET access to uri on host and port
+// until exp (Unix time). The bewit is sent as the bewit query parameter.
+func Bewit(creds Credentials, host string, port string, uri string, exp int64, ext string) string {
+	b, _ := BewitSigner(creds.ID, creds.Signer(), host, port, uri, exp, ext)
+	return b
+}
+
+// BewitSigner is Bewit for uid with the MAC calculated by s.
+func BewitSigner(uid string, s Signer, host string, port string, uri string, exp int64, ext string) (string, error) {
+	mac, err := signMAC(s, "bewit", exp, "", "GET", uri, host, port, "", ext)
+	if err != nil {
+		return "", err
+	}
+	b := fmt.Sprintf(`%s\%d\%s\%s`, uid, exp, mac, ext)
+	return b64.RawURLEncoding.EncodeToString([]byte(b)), nil
+}
+
+// Bewit calculates a bewit for url that is valid for ttl.
+func (c *Client) Bewit(url string, ttl time.Duration, ext string) (string, error) {
+	return c.BewitContext(context.Background(), url, ttl, ext)
+}
+
+// BewitContext is Bewit with a context that is passed on to the Refresh
+// function or CredentialProvider of the client.
+func (c *Client) BewitContext(ctx context.Context, url string, ttl time.Duration, ext string) (string, error) {
+	host, port, uri, err := parseURL(url)
+	if err != nil {
+		return "", err
+	}
+	uid, signer, err := c.signerFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	if AlgorithmName(signer.Algorithm()) == "" {
+		return "", ErrUnknownAlgorithm
+	}
+	exp := time.Now().Add(ttl).Unix()
+	return BewitSigner(uid, signer, host, port, uri, exp, ext)
+}
@@ -0,0 +1,36 @@
+package hawk
+
+import (
+	"crypto"
+	b64 "encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientBewit(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		hc := NewClient("123456", []byte("2983d45yun89q"), crypto.SHA256, 6)
+		bewit, err := hc.Bewit("https://example.com/somewhere/over/the/rainbow", 5*time.Minute, "xandyandz")
+		if err != nil {
+			t.Fatalf("Bewit failed: %s", err.Error())
+		}
+		b, err := b64.RawURLEncoding.DecodeString(bewit)
+		if err != nil {
+			t.Fatalf("Bewit failed: %s", err.Error())
+		}
+		parts := strings.Split(string(b), `\`)
+		if got, want := len(parts), 4; got != want {
+			t.Fatalf("Bewit failed:\n  got parts:  %d\n  want parts: %d", got, want)
+		}
+		if parts[0] != "123456" || parts[3] != "xandyandz" {
+			t.Errorf("Bewit failed: unexpected id or ext: %s", b)
+		}
+	})
+	t.Run("broken-url", func(t *testing.T) {
+		hc := NewClient("123456", []byte("2983d45yun89q"), crypto.SHA256, 6)
+		if _, err := hc.Bewit("ftp://example.com/x", time.Minute, ""); err == nil {
+			t.Errorf("Bewit failed: no error on unsupported scheme")
+		}
+	})
+}
@@ -0,0 +1,91 @@
+//go:build unix
+
+// Command hawk-agent holds Hawk keys and signs for clients over a Unix
+// socket, see package agent.
+//
+// The keys are read from a JSON or YAML credential file, see
+// hawk.LoadCredentialMap, or as JSON from stdin with -credentials -. Like
+// ssh-agent it prints the commands setting HAWK_AGENT_SOCK for the shell:
+//
+//     eval $(vault read -field=credentials secret/hawk | hawk-agent -credentials -)
+//
+// The socket is only accessible to the user running the agent.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	hawk "gitlab.com/tdely/go-hawk"
+	"gitlab.com/tdely/go-hawk/agent"
+)
+
+func main() {
+	socket := flag.String("socket", defaultSocket(), "path of the Unix socket to listen on")
+	credentials := flag.String("credentials", "", "JSON or YAML credential file, - for JSON on stdin")
+	flag.Parse()
+
+	creds, err := readCredentials(*credentials, os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	l, err := listen(*socket)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		l.Close()
+	}()
+
+	fmt.Printf("%s=%s; export %s;\n", agent.SocketEnv, *socket, agent.SocketEnv)
+	os.Stdout.Close()
+	if err := agent.New(creds).Serve(l); err != nil {
+		if _, ok := err.(*net.OpError); !ok {
+			log.Fatal(err)
+		}
+	}
+}
+
+// defaultSocket is hawk-agent.sock in $XDG_RUNTIME_DIR, or a per-user path
+// in the temporary directory.
+func defaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "hawk-agent.sock")
+	}
+	return filepath.Join(os.TempDir(), "hawk-agent-"+strconv.Itoa(os.Getuid())+".sock")
+}
+
+func readCredentials(path string, stdin io.Reader) (hawk.CredentialMap, error) {
+	if path == "-" {
+		return hawk.ReadCredentialMap(stdin)
+	}
+	return hawk.LoadCredentialMap(path)
+}
+
+// listen listens on the Unix socket path, which is removed when the
+// listener is closed, with permissions for the current user only.
+func listen(path string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	l, err := net.Listen("unix", path)
+	syscall.Umask(old)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
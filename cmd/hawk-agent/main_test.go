@@ -0,0 +1,39 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadCredentials(t *testing.T) {
+	creds, err := readCredentials("-", strings.NewReader(`{"jdoe": {"key": "Syp9393", "algorithm": "sha256"}}`))
+	if err != nil {
+		t.Fatalf("readCredentials failed: %s", err.Error())
+	}
+	if got, want := string(creds["jdoe"].Key), "Syp9393"; got != want {
+		t.Errorf("readCredentials failed:\n  got:  %s\n  want: %s", got, want)
+	}
+}
+
+func TestListen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := listen(path)
+	if err != nil {
+		t.Fatalf("listen failed: %s", err.Error())
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %s", err.Error())
+	}
+	if got, want := fi.Mode().Perm(), os.FileMode(0600); got != want {
+		t.Errorf("listen failed:\n  got:  %v\n  want: %v", got, want)
+	}
+	l.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Close failed: socket not removed")
+	}
+}
@@ -0,0 +1,179 @@
+// Command hawk-gateway puts Hawk authentication in front of a backend that
+// has none.
+//
+// Incoming requests are verified against a JSON or YAML credential file,
+// see hawk.LoadCredentialMap, including payload hash, timestamp skew and
+// replayed nonces. Valid requests are forwarded to the upstream with the
+// authenticated Hawk id in a trusted header, X-Hawk-Id by default, and the
+// upstream's responses are signed with Server-Authorization. Requests with
+// a payload hash and a body larger than -max-body are answered with 413
+// Request Entity Too Large, everything else with 401 Unauthorized.
+// Responses larger than -max-signed-body are streamed and signed without a
+// payload hash.
+//
+// The credential file is reloaded when it changes and on SIGHUP. A file
+// that is not valid is logged and ignored, keeping the credentials in use.
+//
+//     hawk-gateway -credentials credentials.json -upstream http://127.0.0.1:3000
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"flag"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	hawk "gitlab.com/tdely/go-hawk"
+)
+
+type config struct {
+	upstream           *url.URL
+	credentials        hawk.CredentialStore
+	algorithms         []crypto.Hash
+	skew               time.Duration
+	idHeader           string
+	requirePayloadHash bool
+	maxBody            int64
+	maxSignedBody      int64
+}
+
+func main() {
+	listen := flag.String("listen", ":8080", "address to listen on")
+	upstream := flag.String("upstream", "", "URL of the upstream server")
+	credentials := flag.String("credentials", "", "JSON or YAML credential file")
+	algorithms := flag.String("algorithms", "sha256,sha384,sha512", "comma separated list of accepted algorithms")
+	skew := flag.Duration("skew", hawk.DefaultSkew, "allowed clock skew")
+	idHeader := flag.String("id-header", "X-Hawk-Id", "header carrying the authenticated id to the upstream")
+	requireHash := flag.Bool("require-hash", false, "reject requests without payload hash")
+	maxBody := flag.Int64("max-body", 10<<20, "largest request body in bytes read to check a payload hash")
+	maxSignedBody := flag.Int64("max-signed-body", 10<<20, "largest response body in bytes signed with a payload hash")
+	flag.Parse()
+
+	cfg := config{skew: *skew, idHeader: *idHeader, requirePayloadHash: *requireHash, maxBody: *maxBody, maxSignedBody: *maxSignedBody}
+	var err error
+	if cfg.upstream, err = url.Parse(*upstream); err != nil || cfg.upstream.Host == "" {
+		log.Fatalf("Invalid upstream URL: %q", *upstream)
+	}
+	store, err := hawk.OpenFileCredentialStore(*credentials)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store.OnReload = func(e hawk.ReloadEvent) {
+		if e.Err != nil {
+			log.Printf("Keeping credentials, reloading %s failed: %s", e.Path, e.Err)
+			return
+		}
+		log.Printf("Reloaded %d credentials from %s", e.Credentials, e.Path)
+	}
+	go store.Watch(context.Background(), 5*time.Second)
+	cfg.credentials = store
+	for _, name := range strings.Split(*algorithms, ",") {
+		alg, err := hawk.LookupAlgorithm(strings.TrimSpace(name))
+		if err != nil {
+			log.Fatalf("%s: %s", err, name)
+		}
+		cfg.algorithms = append(cfg.algorithms, alg)
+	}
+
+	log.Printf("Verifying %s for %s", *listen, cfg.upstream)
+	log.Fatal(http.ListenAndServe(*listen, newGateway(cfg)))
+}
+
+// verified is what the gateway keeps about an authenticated request for
+// signing its response.
+type verified struct {
+	hawk  hawk.Hawk
+	creds hawk.Credentials
+}
+
+type verifiedKey struct{}
+
+// gateway verifies requests before passing them on to the proxy.
+type gateway struct {
+	verifier      *hawk.Verifier
+	idHeader      string
+	maxSignedBody int64
+	proxy         *httputil.ReverseProxy
+}
+
+func newGateway(cfg config) *gateway {
+	g := &gateway{
+		verifier: &hawk.Verifier{
+			Credentials:        cfg.credentials,
+			Algorithms:         cfg.algorithms,
+			Nonces:             &hawk.MemoryNonceStore{Window: cfg.skew},
+			Skew:               cfg.skew,
+			RequirePayloadHash: cfg.requirePayloadHash,
+			MaxPayloadSize:     cfg.maxBody},
+		idHeader:      cfg.idHeader,
+		maxSignedBody: cfg.maxSignedBody,
+		proxy:         httputil.NewSingleHostReverseProxy(cfg.upstream)}
+	g.proxy.ModifyResponse = g.sign
+	return g
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, creds, err := g.verifier.Verify(r)
+	if err == hawk.ErrStaleTimestamp {
+		if challenge, cerr := g.verifier.TimestampChallenge(creds); cerr == nil {
+			w.Header().Set("WWW-Authenticate", challenge)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+	if err == hawk.ErrPayloadTooLarge {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	} else if err == context.Canceled || err == context.DeadlineExceeded {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		w.Header().Set("WWW-Authenticate", `Hawk error="`+err.Error()+`"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	r.Header.Del("Authorization")
+	r.Header.Set(g.idHeader, creds.ID)
+	ctx := context.WithValue(r.Context(), verifiedKey{}, verified{hawk: h, creds: creds})
+	g.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// sign adds Server-Authorization to a response from the upstream. Bodies
+// of up to maxSignedBody bytes are buffered and signed with a payload
+// hash; larger ones are streamed and signed without.
+func (g *gateway) sign(resp *http.Response) error {
+	v, ok := resp.Request.Context().Value(verifiedKey{}).(verified)
+	if !ok {
+		return nil
+	}
+	if resp.ContentLength > g.maxSignedBody {
+		resp.Header.Set("Server-Authorization", v.hawk.GetServerAuthorizationWithoutHash(v.creds.Key, ""))
+		return nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, g.maxSignedBody+1))
+	if err != nil {
+		resp.Body.Close()
+		return err
+	}
+	if int64(len(body)) > g.maxSignedBody {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		resp.Header.Set("Server-Authorization", v.hawk.GetServerAuthorizationWithoutHash(v.creds.Key, ""))
+		return nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	auth := v.hawk.GetServerAuthorization(v.creds.Key, resp.Header.Get("Content-Type"), body, "")
+	resp.Header.Set("Server-Authorization", auth)
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	hawk "gitlab.com/tdely/go-hawk"
+)
+
+func startGateway(t *testing.T) *httptest.Server {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/stream" {
+			// Sent without Content-Length.
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(r.Header.Get("X-Hawk-Id") + " " + r.Header.Get("Authorization") + ": " + string(b)))
+	}))
+	t.Cleanup(up.Close)
+	u, _ := url.Parse(up.URL)
+	g := httptest.NewServer(newGateway(config{
+		upstream: u,
+		credentials: hawk.CredentialMap{
+			"jdoe": {ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256},
+		},
+		skew:          time.Minute,
+		idHeader:      "X-Hawk-Id",
+		maxBody:       128,
+		maxSignedBody: 64}))
+	t.Cleanup(g.Close)
+	return g
+}
+
+func TestGateway(t *testing.T) {
+	g := startGateway(t)
+	t.Run("ok", func(t *testing.T) {
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		req, _ := hc.NewRequest("POST", g.URL+"/hello", strings.NewReader("Hello world!"), "text/plain", "")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %s", err.Error())
+		}
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("Gateway failed:\n  got:  %d\n  want: %d", got, want)
+		}
+		if !hc.ValidateResponse(*resp) {
+			t.Errorf("ValidateResponse failed: response not signed by gateway")
+		}
+		// ValidateResponse consumed the body.
+		req, _ = hc.NewRequest("POST", g.URL+"/hello", strings.NewReader("Hello world!"), "text/plain", "")
+		resp, _ = http.DefaultClient.Do(req)
+		b, _ := ioutil.ReadAll(resp.Body)
+		if got, want := string(b), "jdoe : Hello world!"; got != want {
+			t.Errorf("Gateway failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("large", func(t *testing.T) {
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		payload := strings.Repeat("x", 100)
+		for i := 0; i < 4; i++ {
+			path := []string{"/hello", "/stream"}[i/2]
+			req, _ := hc.NewRequest("POST", g.URL+path, strings.NewReader(payload), "text/plain", "")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do failed: %s", err.Error())
+			}
+			if strings.Contains(resp.Header.Get("Server-Authorization"), "hash=") {
+				t.Errorf("Gateway failed: large response signed with payload hash")
+			}
+			if i%2 == 0 {
+				if !hc.ValidateResponse(*resp) {
+					t.Errorf("ValidateResponse failed: response not signed by gateway")
+				}
+				continue
+			}
+			b, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if got, want := string(b), "jdoe : "+payload; got != want {
+				t.Errorf("Gateway %s failed:\n  got:  %s\n  want: %s", path, got, want)
+			}
+		}
+	})
+	t.Run("large-request", func(t *testing.T) {
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		req, _ := hc.NewRequest("POST", g.URL+"/hello", strings.NewReader(strings.Repeat("x", 200)), "text/plain", "")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %s", err.Error())
+		}
+		resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusRequestEntityTooLarge; got != want {
+			t.Errorf("Gateway failed:\n  got:  %d\n  want: %d", got, want)
+		}
+	})
+	t.Run("spoofed-id", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", g.URL+"/hello", nil)
+		req.Header.Set("X-Hawk-Id", "jdoe")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %s", err.Error())
+		}
+		if got, want := resp.StatusCode, http.StatusUnauthorized; got != want {
+			t.Errorf("Gateway failed:\n  got:  %d\n  want: %d", got, want)
+		}
+	})
+	t.Run("wrong-key", func(t *testing.T) {
+		hc := hawk.NewClient("jdoe", []byte("wrong"), crypto.SHA256, 6)
+		req, _ := hc.NewRequest("GET", g.URL+"/hello", nil, "", "")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %s", err.Error())
+		}
+		if got, want := resp.StatusCode, http.StatusUnauthorized; got != want {
+			t.Errorf("Gateway failed:\n  got:  %d\n  want: %d", got, want)
+		}
+	})
+	t.Run("replay", func(t *testing.T) {
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		req, _ := hc.NewRequest("GET", g.URL+"/hello", nil, "", "")
+		http.DefaultClient.Do(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %s", err.Error())
+		}
+		if got, want := resp.StatusCode, http.StatusUnauthorized; got != want {
+			t.Errorf("Gateway failed:\n  got:  %d\n  want: %d", got, want)
+		}
+	})
+	t.Run("stale", func(t *testing.T) {
+		hd := hawk.Details{
+			Algorithm: crypto.SHA256,
+			Host:      "127.0.0.1",
+			Port:      g.URL[strings.LastIndex(g.URL, ":")+1:],
+			URI:       "/hello",
+			Method:    "GET",
+			Timestamp: time.Now().Add(-time.Hour).Unix()}
+		h, _ := hd.Create()
+		h.Finalize([]byte("Syp9393"))
+		req, _ := http.NewRequest("GET", g.URL+"/hello", nil)
+		req.Header.Set("Authorization", h.GetAuthorization("jdoe"))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %s", err.Error())
+		}
+		if got, want := resp.StatusCode, http.StatusUnauthorized; got != want {
+			t.Errorf("Gateway failed:\n  got:  %d\n  want: %d", got, want)
+		}
+		if !strings.Contains(resp.Header.Get("WWW-Authenticate"), "tsm=") {
+			t.Errorf("Gateway failed: no timestamp challenge")
+		}
+	})
+}
@@ -0,0 +1,184 @@
+// Command hawk-proxy is a signing reverse proxy for clients that cannot do
+// Hawk authentication themselves.
+//
+// It listens locally and forwards every request to the upstream, signed
+// with the configured credentials. The Server-Authorization of each
+// response is validated on the way back; responses that fail are replaced
+// with 502 Bad Gateway, or with -flag passed on with the header
+// Hawk-Response-Valid set to false. Unsigned 401 responses, which is how
+// the upstream rejects a request, are passed on as they are.
+//
+// Responses are passed on as the upstream encoded them, so that their
+// payload hash can be checked. Bodies with a payload hash are buffered up
+// to -max-body bytes for it; larger ones fail validation. Responses signed
+// without a payload hash are streamed.
+//
+//     HAWK_ID=your-hawk-id HAWK_KEY=secret hawk-proxy -upstream https://example.com
+//
+// The key may also be read from a file with -key-file.
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	hawk "gitlab.com/tdely/go-hawk"
+)
+
+// validHeader is set on responses in flag mode.
+const validHeader = "Hawk-Response-Valid"
+
+var (
+	errInvalidResponse = errors.New("Invalid Server-Authorization")
+	errLargeResponse   = errors.New("Response too large to validate")
+)
+
+type config struct {
+	upstream    *url.URL
+	id          string
+	key         []byte
+	algorithm   crypto.Hash
+	ext         string
+	nonceLength int
+	flag        bool
+	maxBody     int64
+}
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:8080", "address to listen on")
+	upstream := flag.String("upstream", "", "URL of the upstream server")
+	id := flag.String("id", os.Getenv("HAWK_ID"), "Hawk id (default $HAWK_ID)")
+	keyFile := flag.String("key-file", "", "file containing the Hawk key (default $HAWK_KEY)")
+	algorithm := flag.String("algorithm", "sha256", "Hawk algorithm")
+	ext := flag.String("ext", "", "ext sent with every request")
+	nonceLength := flag.Int("nonce-length", 6, "length of generated nonces")
+	flagMode := flag.Bool("flag", false, "pass on responses that fail validation with "+validHeader+": false instead of replacing them")
+	maxBody := flag.Int64("max-body", 10<<20, "largest response body in bytes buffered to check its payload hash")
+	flag.Parse()
+
+	cfg := config{id: *id, ext: *ext, nonceLength: *nonceLength, flag: *flagMode, maxBody: *maxBody}
+	var err error
+	if cfg.upstream, err = url.Parse(*upstream); err != nil || cfg.upstream.Host == "" {
+		log.Fatalf("Invalid upstream URL: %q", *upstream)
+	}
+	if cfg.algorithm, err = hawk.LookupAlgorithm(*algorithm); err != nil {
+		log.Fatalf("%s: %s", err, *algorithm)
+	}
+	if *keyFile != "" {
+		b, err := ioutil.ReadFile(*keyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.key = bytes.TrimRight(b, "\r\n")
+	} else {
+		cfg.key = []byte(os.Getenv("HAWK_KEY"))
+	}
+	if cfg.id == "" || len(cfg.key) == 0 {
+		log.Fatal("Hawk id and key are required")
+	}
+
+	log.Printf("Forwarding %s to %s", *listen, cfg.upstream)
+	log.Fatal(http.ListenAndServe(*listen, newProxy(cfg)))
+}
+
+// newProxy returns a reverse proxy to the upstream that signs requests and
+// validates responses.
+func newProxy(cfg config) *httputil.ReverseProxy {
+	hc := hawk.NewClient(cfg.id, cfg.key, cfg.algorithm, cfg.nonceLength)
+	p := httputil.NewSingleHostReverseProxy(cfg.upstream)
+	director := p.Director
+	p.Director = func(req *http.Request) {
+		director(req)
+		req.Host = cfg.upstream.Host
+	}
+	// A Transport that decompresses responses would hand on other bytes
+	// than the upstream hashed.
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.DisableCompression = true
+	p.Transport = &signingTransport{
+		client:  &hc,
+		key:     cfg.key,
+		ext:     cfg.ext,
+		flag:    cfg.flag,
+		maxBody: cfg.maxBody,
+		base:    base}
+	p.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		log.Printf("%s %s: %s", req.Method, req.URL, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+	return p
+}
+
+// signingTransport signs requests and validates the responses.
+type signingTransport struct {
+	client  *hawk.Client
+	key     []byte
+	ext     string
+	flag    bool
+	maxBody int64
+	base    http.RoundTripper
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	h, err := t.client.Sign(req, t.ext)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("Server-Authorization") == "" {
+		return resp, nil
+	}
+
+	check := *resp
+	var valid bool
+	var invalid error = errInvalidResponse
+	if !strings.Contains(resp.Header.Get("Server-Authorization"), `hash="`) {
+		// Only the headers are signed; the body is streamed.
+		check.Body = nil
+		valid = h.ValidateResponse(t.key, check)
+	} else if resp.ContentLength > t.maxBody {
+		invalid = errLargeResponse
+	} else {
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, t.maxBody+1))
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if int64(len(body)) > t.maxBody {
+			resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+			invalid = errLargeResponse
+		} else {
+			resp.Body.Close()
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+			check.Body = ioutil.NopCloser(bytes.NewReader(body))
+			valid = h.ValidateResponse(t.key, check)
+		}
+	}
+	if t.flag {
+		resp.Header.Set(validHeader, strconv.FormatBool(valid))
+		return resp, nil
+	}
+	if !valid {
+		resp.Body.Close()
+		return nil, invalid
+	}
+	return resp, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	hawk "gitlab.com/tdely/go-hawk"
+	"gitlab.com/tdely/go-hawk/hawktest"
+)
+
+func startProxy(t *testing.T, upstream *hawktest.Server, flag bool) *httptest.Server {
+	u, _ := url.Parse(upstream.URL)
+	p := httptest.NewServer(newProxy(config{
+		upstream:    u,
+		id:          "jdoe",
+		key:         []byte("Syp9393"),
+		algorithm:   crypto.SHA256,
+		nonceLength: 6,
+		flag:        flag,
+		maxBody:     64}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// startUpstream runs an upstream that echoes the request body, gzipped if
+// the request accepts it.
+func startUpstream(t *testing.T) *hawktest.Server {
+	s := hawktest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Write([]byte("got: " + string(b)))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte("got: " + string(b)))
+		zw.Close()
+	}))
+	t.Cleanup(s.Close)
+	s.AddCredentials(hawk.Credentials{ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256})
+	return s
+}
+
+func TestProxy(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		up := startUpstream(t)
+		p := startProxy(t, up, false)
+		resp, err := http.Post(p.URL+"/hello?a=1", "text/plain", strings.NewReader("Hello world!"))
+		if err != nil {
+			t.Fatalf("Post failed: %s", err.Error())
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("Proxy failed:\n  got:  %d %s\n  want: %d", got, b, want)
+		}
+		if got, want := string(b), "got: Hello world!"; got != want {
+			t.Errorf("Proxy failed:\n  got:  %s\n  want: %s", got, want)
+		}
+		r := up.Requests()[0]
+		if r.Err != nil || r.Hash == "" || r.URI != "/hello?a=1" {
+			t.Errorf("Proxy failed: unexpected upstream request: %+v", r)
+		}
+	})
+	t.Run("gzip", func(t *testing.T) {
+		up := startUpstream(t)
+		p := startProxy(t, up, false)
+		// A client that does not ask for gzip, and one that does and
+		// decodes it itself.
+		for _, accept := range []string{"", "gzip"} {
+			req, _ := http.NewRequest("POST", p.URL+"/hello", strings.NewReader("Hello world!"))
+			req.Header.Set("Content-Type", "text/plain")
+			if accept != "" {
+				req.Header.Set("Accept-Encoding", accept)
+			}
+			resp, err := (&http.Transport{DisableCompression: true}).RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip failed: %s", err.Error())
+			}
+			body := resp.Body
+			if resp.Header.Get("Content-Encoding") == "gzip" {
+				if body, err = gzip.NewReader(resp.Body); err != nil {
+					t.Fatalf("Proxy %q failed: %s", accept, err.Error())
+				}
+			}
+			b, _ := ioutil.ReadAll(body)
+			resp.Body.Close()
+			if got, want := resp.StatusCode, http.StatusOK; got != want {
+				t.Fatalf("Proxy %q failed:\n  got:  %d %s\n  want: %d", accept, got, b, want)
+			}
+			if got, want := string(b), "got: Hello world!"; got != want {
+				t.Errorf("Proxy %q failed:\n  got:  %s\n  want: %s", accept, got, want)
+			}
+		}
+	})
+	t.Run("large", func(t *testing.T) {
+		up := startUpstream(t)
+		payload := strings.Repeat("x", 100)
+		// Without gzip, which would shrink the response below the limit.
+		plain := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+		resp, err := plain.Post(startProxy(t, up, false).URL+"/hello", "text/plain", strings.NewReader(payload))
+		if err != nil {
+			t.Fatalf("Post failed: %s", err.Error())
+		}
+		resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusBadGateway; got != want {
+			t.Errorf("Proxy failed:\n  got:  %d\n  want: %d", got, want)
+		}
+		resp, err = plain.Post(startProxy(t, up, true).URL+"/hello", "text/plain", strings.NewReader(payload))
+		if err != nil {
+			t.Fatalf("Post failed: %s", err.Error())
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if got, want := resp.Header.Get(validHeader)+" "+string(b), "false got: "+payload; got != want {
+			t.Errorf("Proxy failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("strip", func(t *testing.T) {
+		up := startUpstream(t)
+		up.SetResponseMode(hawktest.TamperHash)
+		p := startProxy(t, up, false)
+		resp, err := http.Get(p.URL + "/hello")
+		if err != nil {
+			t.Fatalf("Get failed: %s", err.Error())
+		}
+		if got, want := resp.StatusCode, http.StatusBadGateway; got != want {
+			t.Errorf("Proxy failed:\n  got:  %d\n  want: %d", got, want)
+		}
+	})
+	t.Run("flag", func(t *testing.T) {
+		up := startUpstream(t)
+		p := startProxy(t, up, true)
+		resp, err := http.Get(p.URL + "/hello")
+		if err != nil {
+			t.Fatalf("Get failed: %s", err.Error())
+		}
+		if got, want := resp.Header.Get(validHeader), "true"; got != want {
+			t.Errorf("Proxy failed:\n  got:  %s\n  want: %s", got, want)
+		}
+		up.SetResponseMode(hawktest.TamperMAC)
+		resp, err = http.Get(p.URL + "/hello")
+		if err != nil {
+			t.Fatalf("Get failed: %s", err.Error())
+		}
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Errorf("Proxy failed:\n  got:  %d\n  want: %d", got, want)
+		}
+		if got, want := resp.Header.Get(validHeader), "false"; got != want {
+			t.Errorf("Proxy failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("rejected", func(t *testing.T) {
+		up := startUpstream(t)
+		up.SetClockOffset(time.Hour)
+		p := startProxy(t, up, false)
+		resp, err := http.Get(p.URL + "/hello")
+		if err != nil {
+			t.Fatalf("Get failed: %s", err.Error())
+		}
+		if got, want := resp.StatusCode, http.StatusUnauthorized; got != want {
+			t.Errorf("Proxy failed:\n  got:  %d\n  want: %d", got, want)
+		}
+		if !strings.Contains(resp.Header.Get("WWW-Authenticate"), "Stale timestamp") {
+			t.Errorf("Proxy failed: challenge not passed on")
+		}
+	})
+}
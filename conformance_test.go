@@ -0,0 +1,121 @@
+package hawk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// vector is a single case from the JSON fixtures in testdata. Which fields
+// are used depends on the fixture type.
+type vector struct {
+	Name        string `json:"name"`
+	Algorithm   string `json:"algorithm"`
+	ID          string `json:"id"`
+	Key         string `json:"key"`
+	Ts          int64  `json:"ts"`
+	Nonce       string `json:"nonce"`
+	Method      string `json:"method"`
+	Resource    string `json:"resource"`
+	Host        string `json:"host"`
+	Port        string `json:"port"`
+	Hash        string `json:"hash"`
+	Ext         string `json:"ext"`
+	ContentType string `json:"contentType"`
+	Payload     string `json:"payload"`
+	Normalized  string `json:"normalized"`
+	MAC         string `json:"mac"`
+	Bewit       string `json:"bewit"`
+}
+
+func loadVectors(t *testing.T, typ string) []vector {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", typ+".json"))
+	if err != nil {
+		t.Fatalf("Failed to read %s vectors: %s", typ, err.Error())
+	}
+	var vs []vector
+	if err := json.Unmarshal(b, &vs); err != nil {
+		t.Fatalf("Failed to parse %s vectors: %s", typ, err.Error())
+	}
+	return vs
+}
+
+// runVectors runs check for every vector of the given fixture type, each as
+// its own subtest so failing cases are reported by name.
+func runVectors(t *testing.T, typ string, check func(t *testing.T, v vector, creds Credentials)) {
+	for _, v := range loadVectors(t, typ) {
+		v := v
+		t.Run(typ+"/"+v.Name, func(t *testing.T) {
+			alg, err := LookupAlgorithm(v.Algorithm)
+			if err != nil {
+				t.Fatalf("Unknown algorithm %q", v.Algorithm)
+			}
+			check(t, v, Credentials{ID: v.ID, Key: []byte(v.Key), Algorithm: alg})
+		})
+	}
+}
+
+func checkMAC(t *testing.T, typ string, v vector, creds Credentials) {
+	if got, want := normalizedString(typ, v.Ts, v.Nonce, v.Method, v.Resource, v.Host, v.Port, v.Hash, v.Ext), v.Normalized; got != want {
+		t.Errorf("normalizedString failed:\n  got:  %q\n  want: %q", got, want)
+	}
+	if got, want := hashMAC(creds.Algorithm, creds.Key, typ, v.Ts, v.Nonce, v.Method, v.Resource, v.Host, v.Port, v.Hash, v.Ext), v.MAC; got != want {
+		t.Errorf("hashMAC failed:\n  got:  %s\n  want: %s", got, want)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	runVectors(t, "header", func(t *testing.T, v vector, creds Credentials) {
+		checkMAC(t, "header", v, creds)
+		hd := Details{
+			Algorithm: creds.Algorithm,
+			Host:      v.Host,
+			Port:      v.Port,
+			URI:       v.Resource,
+			Method:    v.Method,
+			Timestamp: v.Ts,
+			Nonce:     v.Nonce,
+			Ext:       v.Ext}
+		h, err := hd.Create()
+		if err != nil {
+			t.Fatalf("Create failed: %s", err.Error())
+		}
+		h.reqHash = v.Hash
+		h.Finalize(creds.Key)
+		if got, want := h.GetReqMAC(), v.MAC; got != want {
+			t.Errorf("Finalize failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	runVectors(t, "payload", func(t *testing.T, v vector, creds Credentials) {
+		if got, want := normalizedPayload(v.ContentType, []byte(v.Payload)), v.Normalized; got != want {
+			t.Errorf("normalizedPayload failed:\n  got:  %q\n  want: %q", got, want)
+		}
+		if got, want := hashPayload(creds.Algorithm, v.ContentType, []byte(v.Payload)), v.Hash; got != want {
+			t.Errorf("hashPayload failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	runVectors(t, "response", func(t *testing.T, v vector, creds Credentials) {
+		checkMAC(t, "response", v, creds)
+	})
+	runVectors(t, "bewit", func(t *testing.T, v vector, creds Credentials) {
+		checkMAC(t, "bewit", v, creds)
+		if got, want := Bewit(creds, v.Host, v.Port, v.Resource, v.Ts, v.Ext), v.Bewit; got != want {
+			t.Errorf("Bewit failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	runVectors(t, "message", func(t *testing.T, v vector, creds Credentials) {
+		if got, want := hashPayload(creds.Algorithm, "", []byte(v.Payload)), v.Hash; got != want {
+			t.Errorf("hashPayload failed:\n  got:  %s\n  want: %s", got, want)
+		}
+		checkMAC(t, "message", v, creds)
+	})
+	runVectors(t, "ts", func(t *testing.T, v vector, creds Credentials) {
+		if got, want := normalizedTimestamp(v.Ts), v.Normalized; got != want {
+			t.Errorf("normalizedTimestamp failed:\n  got:  %q\n  want: %q", got, want)
+		}
+		if got, want := hashTimestamp(creds.Algorithm, creds.Key, v.Ts), v.MAC; got != want {
+			t.Errorf("hashTimestamp failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+}
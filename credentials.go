@@ -0,0 +1,86 @@
+package hawk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// credentialEntry is the JSON or YAML form of a single id in a credential
+// file.
+type credentialEntry struct {
+	Key       string    `json:"key" yaml:"key"`
+	Algorithm string    `json:"algorithm" yaml:"algorithm"`
+	NotBefore time.Time `json:"not_before" yaml:"not_before"`
+	NotAfter  time.Time `json:"not_after" yaml:"not_after"`
+	Revoked   bool      `json:"revoked" yaml:"revoked"`
+}
+
+// ReadCredentialMap reads credentials in JSON keyed on Hawk id:
+//
+//     {
+//         "dh37fgj492je": {"key": "werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn", "algorithm": "sha256"}
+//     }
+//
+// Every entry must have a key and a registered algorithm. Entries may also
+// set "not_before" and "not_after" as RFC 3339 times, and "revoked".
+func ReadCredentialMap(r io.Reader) (CredentialMap, error) {
+	var entries map[string]credentialEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, err
+	}
+	return newCredentialMap(entries)
+}
+
+// ReadCredentialMapYAML reads credentials in YAML keyed on Hawk id, with
+// the entries of ReadCredentialMap:
+//
+//     dh37fgj492je:
+//       key: werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn
+//       algorithm: sha256
+//       not_after: 2019-11-25T10:00:00Z
+func ReadCredentialMapYAML(r io.Reader) (CredentialMap, error) {
+	var entries map[string]credentialEntry
+	if err := yaml.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, err
+	}
+	return newCredentialMap(entries)
+}
+
+// newCredentialMap checks the entries of a credential file and turns them
+// into credentials.
+func newCredentialMap(entries map[string]credentialEntry) (CredentialMap, error) {
+	m := make(CredentialMap, len(entries))
+	for id, e := range entries {
+		if e.Key == "" {
+			return nil, fmt.Errorf("No key for %s", id)
+		}
+		alg, err := LookupAlgorithm(e.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("%s for %s: %q", err, id, e.Algorithm)
+		}
+		m[id] = Credentials{ID: id, Key: []byte(e.Key), Algorithm: alg, NotBefore: e.NotBefore, NotAfter: e.NotAfter, Revoked: e.Revoked}
+	}
+	return m, nil
+}
+
+// LoadCredentialMap reads credentials from a file, in YAML if its name ends
+// in .yaml or .yml, see ReadCredentialMapYAML, and otherwise in JSON, see
+// ReadCredentialMap.
+func LoadCredentialMap(path string) (CredentialMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		return ReadCredentialMapYAML(f)
+	}
+	return ReadCredentialMap(f)
+}
@@ -0,0 +1,110 @@
+package hawk
+
+import (
+	"context"
+	"crypto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCredentialMap(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		m, err := ReadCredentialMap(strings.NewReader(`{
+			"dh37fgj492je": {"key": "werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn", "algorithm": "sha256"},
+			"legacy": {"key": "secret", "algorithm": "sha1"}
+		}`))
+		if err != nil {
+			t.Fatalf("ReadCredentialMap failed: %s", err.Error())
+		}
+		c, err := m.Lookup(context.Background(), "legacy")
+		if err != nil {
+			t.Fatalf("Lookup failed: %s", err.Error())
+		}
+		if c.ID != "legacy" || string(c.Key) != "secret" || c.Algorithm != crypto.SHA1 {
+			t.Errorf("ReadCredentialMap failed: unexpected credentials: %+v", c)
+		}
+	})
+	t.Run("lifetime", func(t *testing.T) {
+		m, err := ReadCredentialMap(strings.NewReader(`{
+			"jdoe": {"key": "secret", "algorithm": "sha256", "not_before": "2018-11-25T10:00:00Z", "not_after": "2019-11-25T10:00:00Z", "revoked": true}
+		}`))
+		if err != nil {
+			t.Fatalf("ReadCredentialMap failed: %s", err.Error())
+		}
+		c := m["jdoe"]
+		if !c.NotBefore.Equal(time.Date(2018, 11, 25, 10, 0, 0, 0, time.UTC)) || !c.NotAfter.Equal(time.Date(2019, 11, 25, 10, 0, 0, 0, time.UTC)) || !c.Revoked {
+			t.Errorf("ReadCredentialMap failed: unexpected credentials: %+v", c)
+		}
+	})
+	t.Run("unknown-algorithm", func(t *testing.T) {
+		_, err := ReadCredentialMap(strings.NewReader(`{"jdoe": {"key": "secret", "algorithm": "md5"}}`))
+		if err == nil {
+			t.Errorf("ReadCredentialMap failed: no error on unknown algorithm")
+		}
+	})
+	t.Run("missing-key", func(t *testing.T) {
+		_, err := ReadCredentialMap(strings.NewReader(`{"jdoe": {"algorithm": "sha256"}}`))
+		if err == nil {
+			t.Errorf("ReadCredentialMap failed: no error on missing key")
+		}
+	})
+	t.Run("broken-json", func(t *testing.T) {
+		_, err := ReadCredentialMap(strings.NewReader(`{"jdoe": `))
+		if err == nil {
+			t.Errorf("ReadCredentialMap failed: no error on broken JSON")
+		}
+	})
+	t.Run("yaml", func(t *testing.T) {
+		m, err := ReadCredentialMapYAML(strings.NewReader(`
+jdoe:
+  key: secret
+  algorithm: sha256
+  not_before: 2018-11-25T10:00:00Z
+  not_after: "2019-11-25T10:00:00Z"
+  revoked: true
+legacy: {key: "old secret", algorithm: sha1}
+`))
+		if err != nil {
+			t.Fatalf("ReadCredentialMapYAML failed: %s", err.Error())
+		}
+		c := m["jdoe"]
+		if c.ID != "jdoe" || string(c.Key) != "secret" || c.Algorithm != crypto.SHA256 || !c.NotBefore.Equal(time.Date(2018, 11, 25, 10, 0, 0, 0, time.UTC)) || !c.NotAfter.Equal(time.Date(2019, 11, 25, 10, 0, 0, 0, time.UTC)) || !c.Revoked {
+			t.Errorf("ReadCredentialMapYAML failed: unexpected credentials: %+v", c)
+		}
+		if c := m["legacy"]; string(c.Key) != "old secret" || c.Algorithm != crypto.SHA1 {
+			t.Errorf("ReadCredentialMapYAML failed: unexpected credentials: %+v", c)
+		}
+		if _, err := ReadCredentialMapYAML(strings.NewReader("jdoe: {algorithm: md5, key: secret}")); err == nil {
+			t.Errorf("ReadCredentialMapYAML failed: no error on unknown algorithm")
+		}
+		if _, err := ReadCredentialMapYAML(strings.NewReader("jdoe: [")); err == nil {
+			t.Errorf("ReadCredentialMapYAML failed: no error on broken YAML")
+		}
+	})
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "credentials.json")
+		ioutil.WriteFile(path, []byte(`{"jdoe": {"key": "secret", "algorithm": "sha256"}}`), 0600)
+		m, err := LoadCredentialMap(path)
+		if err != nil {
+			t.Fatalf("LoadCredentialMap failed: %s", err.Error())
+		}
+		if got, want := len(m), 1; got != want {
+			t.Errorf("LoadCredentialMap failed:\n  got:  %d\n  want: %d", got, want)
+		}
+		for _, name := range []string{"credentials.yaml", "credentials.yml"} {
+			path := filepath.Join(t.TempDir(), name)
+			ioutil.WriteFile(path, []byte("jdoe: {key: secret, algorithm: sha256}\n"), 0600)
+			m, err := LoadCredentialMap(path)
+			if err != nil || string(m["jdoe"].Key) != "secret" {
+				t.Errorf("LoadCredentialMap %s failed:\n  got:  %+v %v\n  want: %s", name, m, err, "secret")
+			}
+		}
+		if _, err := LoadCredentialMap(path + ".missing"); !os.IsNotExist(err) {
+			t.Errorf("LoadCredentialMap failed: no error on missing file")
+		}
+	})
+}
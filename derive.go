@@ -0,0 +1,59 @@
+package hawk
+
+import (
+	"context"
+	"crypto"
+	"crypto/hkdf"
+)
+
+// DerivedCredentials is a CredentialStore deriving the key of every id from
+// a master secret as HKDF(master, id, Info), with the id as salt, so that
+// only the master secret needs to be kept. Every non-empty id has
+// credentials; the id is authenticated by the MAC alone.
+type DerivedCredentials struct {
+	Master []byte
+	Info   string
+	// Hash is the HKDF hash, SHA256 if not set.
+	Hash crypto.Hash
+	// Algorithm is the Hawk algorithm of derived credentials, SHA256 if
+	// not set.
+	Algorithm crypto.Hash
+	// KeyLength is the length of derived keys, the size of Hash if not set.
+	KeyLength int
+}
+
+// Derive returns the credentials of id, for provisioning devices with the
+// key the store will look up.
+func (d *DerivedCredentials) Derive(id string) (Credentials, error) {
+	if id == "" {
+		return Credentials{}, ErrUnknownCredentials
+	}
+	h := d.Hash
+	if h == 0 {
+		h = crypto.SHA256
+	}
+	if !h.Available() {
+		return Credentials{}, ErrUnknownAlgorithm
+	}
+	n := d.KeyLength
+	if n == 0 {
+		n = h.Size()
+	}
+	key, err := hkdf.Key(h.New, d.Master, []byte(id), d.Info, n)
+	if err != nil {
+		return Credentials{}, err
+	}
+	alg := d.Algorithm
+	if alg == 0 {
+		alg = crypto.SHA256
+	}
+	return Credentials{ID: id, Key: key, Algorithm: alg}, nil
+}
+
+// Lookup implements CredentialStore.
+func (d *DerivedCredentials) Lookup(ctx context.Context, id string) (Credentials, error) {
+	if err := ctx.Err(); err != nil {
+		return Credentials{}, err
+	}
+	return d.Derive(id)
+}
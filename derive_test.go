@@ -0,0 +1,68 @@
+package hawk
+
+import (
+	"context"
+	"crypto"
+	"encoding/hex"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDerivedCredentials(t *testing.T) {
+	cases := []struct {
+		name string
+		d    DerivedCredentials
+		key  string
+	}{
+		{"sha256", DerivedCredentials{Master: []byte("fleet-master-secret"), Info: "hawk device key"},
+			"1af01b2bcb10a2fb3d1a82470ae48c2d6b09bed3f7ced70668833b1c1ec1bf22"},
+		{"sha512-16", DerivedCredentials{Master: []byte("fleet-master-secret"), Info: "hawk device key", Hash: crypto.SHA512, KeyLength: 16},
+			"33750243eb1c2a6c48d58c31cb829e02"},
+	}
+	for _, c := range cases {
+		creds, err := c.d.Lookup(context.Background(), "device-0001")
+		if err != nil {
+			t.Fatalf("Lookup failed for %s: %s", c.name, err.Error())
+		}
+		if got, want := hex.EncodeToString(creds.Key), c.key; got != want {
+			t.Errorf("Lookup failed for %s:\n  got:  %s\n  want: %s", c.name, got, want)
+		}
+		if got, want := creds.Algorithm, crypto.SHA256; got != want {
+			t.Errorf("Lookup failed for %s:\n  got:  %v\n  want: %v", c.name, got, want)
+		}
+	}
+
+	d := &DerivedCredentials{Master: []byte("fleet-master-secret")}
+	if _, err := d.Lookup(context.Background(), ""); err != ErrUnknownCredentials {
+		t.Errorf("Lookup failed:\n  got:  %v\n  want: %v", err, ErrUnknownCredentials)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := d.Lookup(ctx, "device-0001"); err != context.Canceled {
+		t.Errorf("Lookup failed:\n  got:  %v\n  want: %v", err, context.Canceled)
+	}
+}
+
+func TestDerivedVerify(t *testing.T) {
+	d := &DerivedCredentials{Master: []byte("fleet-master-secret"), Info: "hawk device key", Algorithm: crypto.SHA512}
+	creds, err := d.Derive("device-0001")
+	if err != nil {
+		t.Fatalf("Derive failed: %s", err.Error())
+	}
+	c := NewClient(creds.ID, creds.Key, creds.Algorithm, 6)
+	req, _ := c.NewRequest("GET", "http://example.com/resource", nil, "", "")
+	r := httptest.NewRequest(req.Method, req.URL.String(), nil)
+	r.Header = req.Header
+	v := &Verifier{Credentials: d}
+	if _, _, err := v.Verify(r); err != nil {
+		t.Errorf("Verify failed: %s", err.Error())
+	}
+
+	other := &DerivedCredentials{Master: []byte("other-secret"), Info: "hawk device key", Algorithm: crypto.SHA512}
+	v = &Verifier{Credentials: other}
+	req, _ = c.NewRequest("GET", "http://example.com/resource", nil, "", "")
+	r.Header = req.Header
+	if _, _, err := v.Verify(r); err != ErrInvalidMAC {
+		t.Errorf("Verify failed:\n  got:  %v\n  want: %v", err, ErrInvalidMAC)
+	}
+}
@@ -0,0 +1,83 @@
+package hawk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// EncodingMode selects what is hashed when validating a response with a
+// Content-Encoding. The Go Transport decompresses gzip responses
+// transparently unless the request set Accept-Encoding itself, so the
+// body read from a response is not necessarily what the server hashed.
+type EncodingMode int
+
+// Encoding modes.
+const (
+	// HashReceived hashes the body as read from the response, decoded or
+	// not depending on the Transport.
+	HashReceived EncodingMode = iota
+	// HashWire hashes the encoded bytes as sent by the server. Requests
+	// are sent with Accept-Encoding: gzip unless they set it, which keeps
+	// the Transport from decoding responses.
+	HashWire
+	// HashDecoded hashes the decoded entity, decoding gzip and deflate
+	// bodies the Transport did not.
+	HashDecoded
+)
+
+// Errors returned when preparing a response payload for hashing.
+var (
+	ErrDecodedResponse     = errors.New("Response decoded by Transport, wire bytes lost")
+	ErrUnsupportedEncoding = errors.New("Unsupported Content-Encoding")
+)
+
+// payload returns the bytes of the body content of r to hash.
+func (m EncodingMode) payload(r http.Response, content []byte) ([]byte, error) {
+	switch m {
+	case HashWire:
+		if r.Uncompressed {
+			return nil, ErrDecodedResponse
+		}
+	case HashDecoded:
+		return decode(r.Header.Get("Content-Encoding"), content)
+	}
+	return content, nil
+}
+
+// decode decodes content encoded with the comma separated encodings, in
+// the order they were applied.
+func decode(encodings string, content []byte) ([]byte, error) {
+	if encodings == "" {
+		return content, nil
+	}
+	codings := strings.Split(encodings, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		var rd io.ReadCloser
+		var err error
+		switch strings.ToLower(strings.TrimSpace(codings[i])) {
+		case "identity":
+			continue
+		case "gzip", "x-gzip":
+			rd, err = gzip.NewReader(bytes.NewReader(content))
+		case "deflate":
+			rd, err = zlib.NewReader(bytes.NewReader(content))
+		default:
+			return nil, ErrUnsupportedEncoding
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err = ioutil.ReadAll(rd)
+		rd.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
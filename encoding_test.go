@@ -0,0 +1,127 @@
+package hawk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func gzipped(b []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
+
+// startEncodingServer serves gzip encoded responses, hashing the wire bytes
+// if the path is /wire and the decoded entity otherwise.
+func startEncodingServer(t *testing.T) *httptest.Server {
+	v := &Verifier{Credentials: CredentialMap{
+		"jdoe": {ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256},
+	}}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, creds, err := v.Verify(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		entity := []byte("Hello world!")
+		wire := gzipped(entity)
+		hashed := entity
+		if r.URL.Path == "/wire" {
+			hashed = wire
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Server-Authorization", h.GetServerAuthorization(creds.Key, "text/plain", hashed, ""))
+		w.Write(wire)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestContentEncoding(t *testing.T) {
+	s := startEncodingServer(t)
+	cases := []struct {
+		name           string
+		mode           EncodingMode
+		path           string
+		acceptEncoding string
+		valid          bool
+	}{
+		{"received-wire", HashReceived, "/wire", "", false},
+		{"received-wire-accept", HashReceived, "/wire", "gzip", true},
+		{"received-decoded", HashReceived, "/decoded", "", true},
+		{"wire", HashWire, "/wire", "", true},
+		{"wire-decoded", HashWire, "/decoded", "", false},
+		{"decoded", HashDecoded, "/decoded", "", true},
+		{"decoded-accept", HashDecoded, "/decoded", "gzip", true},
+		{"decoded-wire", HashDecoded, "/wire", "gzip", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hc := NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+			hc.ContentEncoding = c.mode
+			req, _ := http.NewRequest("GET", s.URL+c.path, nil)
+			if c.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", c.acceptEncoding)
+			}
+			h, err := hc.Sign(req, "")
+			if err != nil {
+				t.Fatalf("Sign failed: %s", err.Error())
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do failed: %s", err.Error())
+			}
+			if got, want := h.ValidateResponse([]byte("Syp9393"), *resp), c.valid; got != want {
+				t.Errorf("ValidateResponse failed:\n  got:  %t\n  want: %t", got, want)
+			}
+		})
+	}
+
+	t.Run("client", func(t *testing.T) {
+		hc := NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		hc.ContentEncoding = HashWire
+		req, _ := hc.NewRequest("GET", s.URL+"/wire", nil, "", "")
+		if got, want := req.Header.Get("Accept-Encoding"), "gzip"; got != want {
+			t.Errorf("NewRequest failed:\n  got:  %s\n  want: %s", got, want)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %s", err.Error())
+		}
+		if !hc.ValidateResponse(*resp) {
+			t.Errorf("ValidateResponse failed: wire hash not valid")
+		}
+	})
+}
+
+func TestEncodingPayload(t *testing.T) {
+	var deflated bytes.Buffer
+	w := zlib.NewWriter(&deflated)
+	w.Write(gzipped([]byte("Hello world!")))
+	w.Close()
+	r := http.Response{Header: http.Header{"Content-Encoding": {"gzip, deflate"}}}
+	b, err := HashDecoded.payload(r, deflated.Bytes())
+	if err != nil {
+		t.Fatalf("payload failed: %s", err.Error())
+	}
+	if got, want := string(b), "Hello world!"; got != want {
+		t.Errorf("payload failed:\n  got:  %s\n  want: %s", got, want)
+	}
+
+	r = http.Response{Header: http.Header{"Content-Encoding": {"br"}}}
+	if _, err := HashDecoded.payload(r, []byte("Hello world!")); err != ErrUnsupportedEncoding {
+		t.Errorf("payload failed:\n  got:  %v\n  want: %v", err, ErrUnsupportedEncoding)
+	}
+	r = http.Response{Header: http.Header{}, Uncompressed: true}
+	if _, err := HashWire.payload(r, []byte("Hello world!")); err != ErrDecodedResponse {
+		t.Errorf("payload failed:\n  got:  %v\n  want: %v", err, ErrDecodedResponse)
+	}
+}
@@ -0,0 +1,140 @@
+package hawk
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ReloadEvent reports an attempt of a FileCredentialStore to reload its
+// file. If Err is set the file was rejected and the previous credentials
+// are still in use.
+type ReloadEvent struct {
+	Path        string
+	Time        time.Time
+	Credentials int
+	Err         error
+}
+
+// FileCredentialStore is a CredentialStore backed by a JSON or YAML
+// credential file, see LoadCredentialMap, that is reloaded when the file changes or
+// the process receives SIGHUP, once Watch is running. The new file is
+// parsed and validated in full before it replaces the credentials in use,
+// and a file that fails is ignored until it changes again. Lookups are
+// never blocked by a reload; those in flight finish with the credentials
+// they started with.
+//
+// Files are best replaced by renaming a complete file over the old one, as
+// a file being written may be read half-way. A FileCredentialStore is
+// created with OpenFileCredentialStore; the zero value has no file and
+// fails lookups with ErrNoCredentialStore.
+type FileCredentialStore struct {
+	// OnReload, if set, is called after every reload attempt, from the
+	// goroutine that made it.
+	OnReload func(ReloadEvent)
+
+	path    string
+	creds   atomic.Value
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// OpenFileCredentialStore loads the credentials in the file at path.
+func OpenFileCredentialStore(path string) (*FileCredentialStore, error) {
+	s := &FileCredentialStore{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Lookup returns the credentials stored for id.
+func (s *FileCredentialStore) Lookup(ctx context.Context, id string) (Credentials, error) {
+	m, ok := s.creds.Load().(CredentialMap)
+	if !ok {
+		return Credentials{}, ErrNoCredentialStore
+	}
+	return m.Lookup(ctx, id)
+}
+
+// Reload reads the file and, if it is valid, swaps it in.
+func (s *FileCredentialStore) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fi, err := os.Stat(s.path)
+	if err == nil {
+		return s.reload(fi)
+	}
+	s.event(0, err)
+	return err
+}
+
+// reload loads the file last seen with fi. It must be called with s.mu
+// held.
+func (s *FileCredentialStore) reload(fi os.FileInfo) error {
+	// The file is remembered as seen even if it is invalid, so that it is
+	// not retried until it changes.
+	s.modTime, s.size = fi.ModTime(), fi.Size()
+	m, err := LoadCredentialMap(s.path)
+	if err != nil {
+		s.event(0, err)
+		return err
+	}
+	s.creds.Store(m)
+	s.event(len(m), nil)
+	return nil
+}
+
+func (s *FileCredentialStore) event(n int, err error) {
+	if s.OnReload != nil {
+		s.OnReload(ReloadEvent{Path: s.path, Time: time.Now(), Credentials: n, Err: err})
+	}
+}
+
+// changed reloads the file if its modification time or size differ from
+// when it was last loaded.
+func (s *FileCredentialStore) changed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		// A file being replaced may briefly be missing; the old
+		// credentials stay until there is a new one.
+		return
+	}
+	if fi.ModTime().Equal(s.modTime) && fi.Size() == s.size {
+		return
+	}
+	s.reload(fi)
+}
+
+// Watch checks the file for changes every interval and reloads it on
+// SIGHUP, until ctx is done:
+//
+//     go store.Watch(ctx, 5*time.Second)
+func (s *FileCredentialStore) Watch(ctx context.Context, interval time.Duration) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	return s.watch(ctx, interval, hup)
+}
+
+func (s *FileCredentialStore) watch(ctx context.Context, interval time.Duration, hup <-chan os.Signal) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-hup:
+			s.Reload()
+		case <-t.C:
+			s.changed()
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package hawk
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFileCredentialStoreZero(t *testing.T) {
+	var s FileCredentialStore
+	if _, err := s.Lookup(context.Background(), "jdoe"); err != ErrNoCredentialStore {
+		t.Errorf("Lookup failed:\n  got:  %v\n  want: %v", err, ErrNoCredentialStore)
+	}
+}
+
+func TestFileCredentialStoreYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.yaml")
+	ioutil.WriteFile(path, []byte("jdoe:\n  key: secret\n  algorithm: sha256\n"), 0600)
+	s, err := OpenFileCredentialStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileCredentialStore failed: %s", err.Error())
+	}
+	ioutil.WriteFile(path, []byte("jdoe:\n  key: rotated\n  algorithm: sha256\n"), 0600)
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Reload failed: %s", err.Error())
+	}
+	if c, err := s.Lookup(context.Background(), "jdoe"); err != nil || string(c.Key) != "rotated" {
+		t.Errorf("Lookup failed:\n  got:  %q %v\n  want: %q %v", c.Key, err, "rotated", nil)
+	}
+}
+
+func TestFileCredentialStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if _, err := OpenFileCredentialStore(path); err == nil {
+		t.Errorf("OpenFileCredentialStore failed: no error on missing file")
+	}
+	ioutil.WriteFile(path, []byte(`{"jdoe": {"key": "secret", "algorithm": "sha256"}}`), 0600)
+	s, err := OpenFileCredentialStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileCredentialStore failed: %s", err.Error())
+	}
+	events := make(chan ReloadEvent, 10)
+	s.OnReload = func(e ReloadEvent) { events <- e }
+
+	// replace writes a new file and gives it a modification time of its
+	// own, as file systems may not tell writes in quick succession apart.
+	mtime := time.Now()
+	replace := func(content string) {
+		mtime = mtime.Add(time.Second)
+		tmp := path + ".tmp"
+		ioutil.WriteFile(tmp, []byte(content), 0600)
+		os.Chtimes(tmp, mtime, mtime)
+		os.Rename(tmp, path)
+	}
+	lookup := func(id string) string {
+		c, err := s.Lookup(ctx, id)
+		if err != nil {
+			return err.Error()
+		}
+		return string(c.Key)
+	}
+
+	t.Run("reload", func(t *testing.T) {
+		replace(`{"jdoe": {"key": "rotated", "algorithm": "sha256"}, "jroe": {"key": "new", "algorithm": "sha256"}}`)
+		if err := s.Reload(); err != nil {
+			t.Fatalf("Reload failed: %s", err.Error())
+		}
+		if e := <-events; e.Err != nil || e.Credentials != 2 || e.Path != path {
+			t.Errorf("OnReload failed: unexpected event: %+v", e)
+		}
+		if got, want := lookup("jdoe"), "rotated"; got != want {
+			t.Errorf("Lookup failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		for _, content := range []string{
+			`{"jdoe": {"key": "broken", "algorithm": "sha256"}, "jroe": `,
+			`{"jdoe": {"key": "broken", "algorithm": "md5"}}`,
+		} {
+			replace(content)
+			if err := s.Reload(); err == nil {
+				t.Errorf("Reload failed: no error on %s", content)
+			}
+			if e := <-events; e.Err == nil {
+				t.Errorf("OnReload failed: unexpected event: %+v", e)
+			}
+			if got, want := lookup("jdoe"), "rotated"; got != want {
+				t.Errorf("Lookup failed:\n  got:  %s\n  want: %s", got, want)
+			}
+		}
+	})
+	t.Run("watch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		hup := make(chan os.Signal)
+		done := make(chan error)
+		go func() { done <- s.watch(ctx, 10*time.Millisecond, hup) }()
+
+		replace(`{"jdoe": {"key": "watched", "algorithm": "sha256"}}`)
+		if e := <-events; e.Err != nil || e.Credentials != 1 {
+			t.Errorf("OnReload failed: unexpected event: %+v", e)
+		}
+		if got, want := lookup("jdoe"), "watched"; got != want {
+			t.Errorf("Lookup failed:\n  got:  %s\n  want: %s", got, want)
+		}
+		// An unchanged file is only reloaded on SIGHUP.
+		time.Sleep(50 * time.Millisecond)
+		select {
+		case e := <-events:
+			t.Errorf("OnReload failed: unexpected event: %+v", e)
+		default:
+		}
+		hup <- syscall.SIGHUP
+		if e := <-events; e.Err != nil || e.Credentials != 1 {
+			t.Errorf("OnReload failed: unexpected event: %+v", e)
+		}
+
+		cancel()
+		if got, want := <-done, context.Canceled; got != want {
+			t.Errorf("Watch failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,208 @@
+package hawk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minCompactRecords is the number of records below which the log of a
+// FileNonceStore is never compacted.
+const minCompactRecords = 1024
+
+// FileNonceStore is a NonceStore that survives restarts. Nonces are kept in
+// memory as by MemoryNonceStore and appended to a log file, from which the
+// nonces still within the window are reloaded when the store is opened.
+// The log is compacted when most of its records have expired.
+type FileNonceStore struct {
+	// SyncWrites makes Check sync the log to disk before accepting a
+	// nonce, so that not even a crash of the host loses it.
+	SyncWrites bool
+
+	mu      sync.Mutex
+	path    string
+	window  time.Duration
+	f       *os.File
+	nonces  map[string]int64
+	records int
+	pruned  int64
+}
+
+// OpenFileNonceStore opens the nonce log at path, creating it if it does
+// not exist, and remembers nonces for window, DefaultNonceWindow if 0.
+func OpenFileNonceStore(path string, window time.Duration) (*FileNonceStore, error) {
+	if window == 0 {
+		window = DefaultNonceWindow
+	}
+	s := &FileNonceStore{path: path, window: window, nonces: make(map[string]int64)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the nonces within the window from the log. A partially
+// written last record is ignored.
+func (s *FileNonceStore) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	oldest := time.Now().Unix() - int64(s.window.Seconds())
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r nonceRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			continue
+		}
+		if r.Ts >= oldest {
+			s.nonces[r.key()] = r.Ts
+		}
+	}
+	return sc.Err()
+}
+
+// nonceRecord is a line in the log.
+type nonceRecord struct {
+	ID    string `json:"id"`
+	Nonce string `json:"nonce"`
+	Ts    int64  `json:"ts"`
+}
+
+func (r nonceRecord) key() string {
+	return r.ID + "\x00" + r.Nonce
+}
+
+// Check records nonce for id and returns ErrReplayedNonce if it was already
+// used within the window. The nonce is written to the log before it is
+// accepted.
+func (s *FileNonceStore) Check(ctx context.Context, id string, nonce string, ts int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	oldest := now - int64(s.window.Seconds())
+	if ts < oldest {
+		return ErrStaleTimestamp
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	if now-s.pruned >= int64(s.window.Seconds()) {
+		for k, t := range s.nonces {
+			if t < oldest {
+				delete(s.nonces, k)
+			}
+		}
+		s.pruned = now
+		if s.records >= minCompactRecords && s.records > 2*len(s.nonces) {
+			if err := s.compact(); err != nil {
+				return err
+			}
+		}
+	}
+	r := nonceRecord{ID: id, Nonce: nonce, Ts: ts}
+	if _, ok := s.nonces[r.key()]; ok {
+		return ErrReplayedNonce
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if s.SyncWrites {
+		if err := s.f.Sync(); err != nil {
+			return err
+		}
+	}
+	s.records++
+	s.nonces[r.key()] = ts
+	return nil
+}
+
+// compact rewrites the log with only the nonces in memory, replacing the
+// old log atomically, and opens it for appending.
+func (s *FileNonceStore) compact() error {
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for k, ts := range s.nonces {
+		id, nonce, _ := strings.Cut(k, "\x00")
+		if err := enc.Encode(nonceRecord{ID: id, Nonce: nonce, Ts: ts}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	if s.f != nil {
+		s.f.Close()
+	}
+	s.f, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	s.records = len(s.nonces)
+	return nil
+}
+
+// Compact prunes expired nonces and rewrites the log.
+func (s *FileNonceStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	now := time.Now().Unix()
+	oldest := now - int64(s.window.Seconds())
+	for k, t := range s.nonces {
+		if t < oldest {
+			delete(s.nonces, k)
+		}
+	}
+	s.pruned = now
+	return s.compact()
+}
+
+// Close closes the log. Check fails after Close.
+func (s *FileNonceStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
@@ -0,0 +1,121 @@
+package hawk
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileNonceStore(t *testing.T) {
+	ctx := context.Background()
+	t.Run("restart", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nonces.log")
+		s, err := OpenFileNonceStore(path, time.Minute)
+		if err != nil {
+			t.Fatalf("OpenFileNonceStore failed: %s", err.Error())
+		}
+		ts := time.Now().Unix()
+		if err := s.Check(ctx, "jdoe", "abc123", ts); err != nil {
+			t.Fatalf("Check failed: %s", err.Error())
+		}
+		s.Close()
+		if err := s.Check(ctx, "jdoe", "def456", ts); err != os.ErrClosed {
+			t.Errorf("Check failed:\n  got:  %v\n  want: %v", err, os.ErrClosed)
+		}
+
+		s, err = OpenFileNonceStore(path, time.Minute)
+		if err != nil {
+			t.Fatalf("OpenFileNonceStore failed: %s", err.Error())
+		}
+		defer s.Close()
+		if got, want := s.Check(ctx, "jdoe", "abc123", ts), ErrReplayedNonce; got != want {
+			t.Errorf("Check failed:\n  got:  %v\n  want: %v", got, want)
+		}
+		if err := s.Check(ctx, "jroe", "abc123", ts); err != nil {
+			t.Errorf("Check failed: %s", err.Error())
+		}
+	})
+	t.Run("load", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nonces.log")
+		now := time.Now().Unix()
+		log := fmt.Sprintf("{\"id\":\"jdoe\",\"nonce\":\"old\",\"ts\":%d}\n{\"id\":\"jdoe\",\"nonce\":\"new\",\"ts\":%d}\n{\"id\":\"jdoe\",\"no", now-3600, now)
+		ioutil.WriteFile(path, []byte(log), 0600)
+		s, err := OpenFileNonceStore(path, time.Minute)
+		if err != nil {
+			t.Fatalf("OpenFileNonceStore failed: %s", err.Error())
+		}
+		defer s.Close()
+		if got, want := len(s.nonces), 1; got != want {
+			t.Fatalf("OpenFileNonceStore failed:\n  got:  %d nonces\n  want: %d nonces", got, want)
+		}
+		if got, want := s.Check(ctx, "jdoe", "new", now), ErrReplayedNonce; got != want {
+			t.Errorf("Check failed:\n  got:  %v\n  want: %v", got, want)
+		}
+		// The log was compacted on opening and new records are appended
+		// on lines of their own.
+		s.Check(ctx, "jdoe", "newer", now)
+		b, _ := ioutil.ReadFile(path)
+		if got, want := bytes.Count(b, []byte("\n")), 2; got != want {
+			t.Errorf("OpenFileNonceStore failed:\n  got:  %d records\n  want: %d records\n%s", got, want, b)
+		}
+	})
+	t.Run("stale", func(t *testing.T) {
+		s, _ := OpenFileNonceStore(filepath.Join(t.TempDir(), "nonces.log"), time.Minute)
+		defer s.Close()
+		if got, want := s.Check(ctx, "jdoe", "abc123", time.Now().Add(-2*time.Minute).Unix()), ErrStaleTimestamp; got != want {
+			t.Errorf("Check failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+	t.Run("compact", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nonces.log")
+		s, _ := OpenFileNonceStore(path, time.Minute)
+		defer s.Close()
+		s.SyncWrites = true
+		now := time.Now().Unix()
+		for i := 0; i < 10; i++ {
+			s.Check(ctx, "jdoe", fmt.Sprint(i), now)
+		}
+		s.mu.Lock()
+		for k := range s.nonces {
+			s.nonces[k] = now - 3600
+		}
+		s.mu.Unlock()
+		if err := s.Compact(); err != nil {
+			t.Fatalf("Compact failed: %s", err.Error())
+		}
+		if fi, _ := os.Stat(path); fi.Size() != 0 {
+			t.Errorf("Compact failed: %d bytes left in log", fi.Size())
+		}
+		if err := s.Check(ctx, "jdoe", "0", now); err != nil {
+			t.Errorf("Check failed: %s", err.Error())
+		}
+	})
+	t.Run("concurrent", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nonces.log")
+		s, _ := OpenFileNonceStore(path, time.Minute)
+		ts := time.Now().Unix()
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 50; i++ {
+					s.Check(ctx, "jdoe", fmt.Sprint(g, "-", i), ts)
+				}
+			}(g)
+		}
+		wg.Wait()
+		s.Close()
+		s, _ = OpenFileNonceStore(path, time.Minute)
+		defer s.Close()
+		if got, want := len(s.nonces), 200; got != want {
+			t.Errorf("OpenFileNonceStore failed:\n  got:  %d nonces\n  want: %d nonces", got, want)
+		}
+	})
+}
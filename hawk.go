@@ -50,19 +50,34 @@
 //     req.Header.Add("Authorization", auth)
 //     resp, err := c.Do(req)
 //     // valid := h.ValidateResponse([]byte("justtesting"), *resp)
+//
+// Requests can be verified on the server side with a Verifier. Only the
+// algorithms in Verifier.Algorithms are accepted, by default SHA256, SHA384
+// and SHA512:
+//
+//     v := &hawk.Verifier{Credentials: hawk.CredentialMap{
+//         "your-hawk-id": {ID: "your-hawk-id", Key: []byte("secret"), Algorithm: crypto.SHA256},
+//     }, Nonces: &hawk.MemoryNonceStore{}}
+//     h, creds, err := v.Verify(req)
+//
+// Algorithms are named as in Hawk credentials, e.g. hawk.LookupAlgorithm("sha256").
 package hawk
 
 import (
+	"bytes"
+	"context"
 	"crypto"
 	"crypto/hmac"
-	b64 "encoding/base64"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unsafe"
 )
@@ -90,15 +105,20 @@ type Hawk struct {
 	respExt         string
 	respHash        string
 	respMAC         string
+
+	encoding EncodingMode
 }
 
 // Create takes the data in Details and creates a Hawk instance.
 // Nonce and/or Timestamp may be omitted from Details to automatically
-// create these values. Error on missing Host, Port, URI, Method, or Algorithm.
+// create these values. Error on missing Host, Port, URI, Method, or on an
+// Algorithm that is not registered.
 func (hd *Details) Create() (Hawk, error) {
 	var err error
-	if !hd.Algorithm.Available() {
+	if hd.Algorithm == 0 {
 		err = fmt.Errorf("No algorithm provided")
+	} else if AlgorithmName(hd.Algorithm) == "" {
+		err = ErrUnknownAlgorithm
 	} else if hd.Host == "" {
 		err = fmt.Errorf("No host provided")
 	} else if hd.Port == "" {
@@ -109,7 +129,7 @@ func (hd *Details) Create() (Hawk, error) {
 		err = fmt.Errorf("No method provided")
 	}
 	if err != nil {
-		return Hawk{}, nil
+		return Hawk{}, err
 	}
 	h := Hawk{
 		algorithm:      hd.Algorithm,
@@ -147,13 +167,36 @@ type Details struct {
 }
 
 // Client is for creating HTTP requests that are automatically set up
-// for Hawk authentication.
+// for Hawk authentication. A Client may be used by multiple goroutines at
+// once, and must not be copied after first use.
 type Client struct {
 	uid         string
 	key         []byte
 	hash        crypto.Hash
 	NonceLength int
-	hawk        Hawk
+	// BaseURL is prepended to URLs passed to NewRequest that are not
+	// absolute.
+	BaseURL string
+	// Ext is sent with requests made without ext of their own.
+	Ext string
+	// ContentEncoding selects what is hashed when validating responses
+	// with a Content-Encoding.
+	ContentEncoding EncodingMode
+	// Refresh, if set, is called for new credentials when those of the
+	// client are no longer valid, see NewClientWithCredentials.
+	Refresh func(ctx context.Context) (Credentials, error)
+
+	// mu guards the credentials the client signs with and the request
+	// last made, which are replaced by refresh and key rotation.
+	mu         sync.Mutex
+	refreshMu  sync.Mutex
+	creds      Credentials
+	keys       []Credentials
+	active     int
+	provider   CredentialProvider
+	signer     Signer
+	hawk       Hawk
+	hawkSigner Signer
 }
 
 // Regexp pattern for capturing HTTP/HTTPS URLs
@@ -163,6 +206,8 @@ const urlPattern = "^(http|https)://([^:/]+)(:([0-9]+))?(/(.+)?)?$"
 // Regexp pattern for capturing Hawk header elements.
 const hawkPattern = `(\w+)="([^"]*)"`
 
+var urlRe = regexp.MustCompile(urlPattern)
+
 // Constants for nonce creation
 const (
 	letterBytes   = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -173,11 +218,16 @@ const (
 
 var randSrc = rand.NewSource(time.Now().UnixNano())
 
+// randMu guards randSrc, which is not safe for concurrent use.
+var randMu sync.Mutex
+
 // NewNonce creates a new n-length nonce.
 func NewNonce(n int) string {
 	// Author: András Belicza (icza)
 	// https://stackoverflow.com/a/31832326
 	b := make([]byte, n)
+	randMu.Lock()
+	defer randMu.Unlock()
 	for i, cache, remain := n-1, randSrc.Int63(), letterIdxMax; i >= 0; {
 		if remain == 0 {
 			cache, remain = randSrc.Int63(), letterIdxMax
@@ -192,14 +242,29 @@ func NewNonce(n int) string {
 	return *(*string)(unsafe.Pointer(&b))
 }
 
+// normalizeContentType reduces a Content-Type value to the form used in
+// payload hashes: parameters removed, surrounding whitespace trimmed and
+// lowercased, as in the reference implementation.
+func normalizeContentType(ct string) string {
+	if i := strings.Index(ct, ";"); i != -1 {
+		ct = ct[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(ct))
+}
+
+func normalizedPayload(ct string, c []byte) string {
+	return "hawk.1.payload\n" + normalizeContentType(ct) + "\n" + string(c) + "\n"
+}
+
 func hashPayload(h crypto.Hash, ct string, c []byte) string {
-	pl := fmt.Sprintf(
-		"hawk.1.payload\n%s\n%s\n",
-		ct, c)
-	hasher := h.New()
-	hasher.Write([]byte(pl))
-	plHash := hasher.Sum(nil)
-	return b64.StdEncoding.EncodeToString(plHash[:])
+	hasher := getHash(h)
+	hasher.buf = append(append(append(hasher.buf[:0], "hawk.1.payload\n"...), normalizeContentType(ct)...), '\n')
+	hasher.Write(hasher.buf)
+	hasher.Write(c)
+	hasher.Write(hasher.buf[len(hasher.buf)-1:])
+	plHash := encodeSum(hasher.Sum(hasher.sum[:0]))
+	putHash(h, hasher)
+	return plHash
 }
 
 // Validate calculates and sets hash for Hawk request payload validation.
@@ -215,60 +280,85 @@ func (h *Hawk) Validate() bool {
 // ValidateResponse validates the response to a Hawk request for message
 // authenticity, and if hash is sent: payload verification.
 func (h *Hawk) ValidateResponse(k []byte, r http.Response) bool {
-	ct := r.Header.Get("Content-Type")
-	if ct != "" && strings.Index(ct, ";") != -1 {
-		h.respContentType = ct[:strings.Index(ct, ";")]
-	} else if ct != "" {
-		h.respContentType = ct
-	}
-	auth := r.Header.Get("Server-Authorization")
-	re := regexp.MustCompile(hawkPattern)
-	elements := re.FindAllSubmatch([]byte(auth), -1)
-	for _, e := range elements {
-		key := string(e[1])
-		val := string(e[2])
-		switch key {
-		case "ext":
-			h.respExt = val
-		case "hash":
-			h.respHash = val
-		case "mac":
-			h.respMAC = val
-		}
-	}
+	return h.ValidateResponseSigner(Credentials{Key: k, Algorithm: h.algorithm}.Signer(), r)
+}
+
+// ValidateResponseSigner is ValidateResponse with the MAC calculated by s.
+func (h *Hawk) ValidateResponseSigner(s Signer, r http.Response) bool {
+	h.respContentType = normalizeContentType(r.Header.Get("Content-Type"))
+	attrs, _ := parseHeader(r.Header.Get("Server-Authorization"))
+	h.respExt = attrs["ext"]
+	h.respHash = attrs["hash"]
+	h.respMAC = attrs["mac"]
 	if r.Body != nil {
 		h.respContent, _ = ioutil.ReadAll(r.Body)
 		r.Body.Close()
 	}
 
-	calcHash := hashPayload(h.algorithm, h.respContentType, h.respContent)
-	if h.respHash != "" && h.respHash != calcHash {
+	if h.respHash != "" {
+		content, err := h.encoding.payload(r, h.respContent)
+		if err != nil || h.respHash != hashPayload(h.algorithm, h.respContentType, content) {
+			return false
+		}
+	}
+	if s.Algorithm() != h.algorithm {
 		return false
 	}
-	calcMAC := hashMAC(h.algorithm, k, h.timestamp, h.nonce, h.method, h.uri, h.host, h.port, h.respHash, h.respExt)
-	if h.respMAC != calcMAC {
+	calcMAC, err := signMAC(s, "response", h.timestamp, h.nonce, h.method, h.uri, h.host, h.port, h.respHash, h.respExt)
+	if err != nil || !hmac.Equal([]byte(h.respMAC), []byte(calcMAC)) {
 		return false
 	}
 	return true
 }
 
-func hashMAC(h crypto.Hash, k []byte, ts int64, n string, mtd string, uri string, hst string, p string, hsh string, ext string) string {
-	hdr := []byte(fmt.Sprintf(
-		"hawk.1.header\n%d\n%s\n%s\n%s\n%s\n%s\n%s\n%s\n",
-		ts, n, mtd, uri, hst, p, hsh, ext))
-	m := hmac.New(h.New, k)
-	m.Write(hdr)
-	mac := m.Sum(nil)
-	return b64.StdEncoding.EncodeToString(mac[:])
+// extEscaper escapes ext the way the reference implementation does before
+// it is included in a normalized string.
+var extEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+
+// normalizedString returns the string a MAC of the given type (header,
+// response, bewit or message) is calculated over.
+func normalizedString(typ string, ts int64, n string, mtd string, uri string, hst string, p string, hsh string, ext string) string {
+	return string(appendNormalized(nil, typ, ts, n, mtd, uri, hst, p, hsh, ext))
+}
+
+func hashMAC(h crypto.Hash, k []byte, typ string, ts int64, n string, mtd string, uri string, hst string, p string, hsh string, ext string) string {
+	s, pool := getMAC(h, k)
+	s.buf = appendNormalized(s.buf[:0], typ, ts, n, mtd, uri, hst, p, hsh, ext)
+	s.Write(s.buf)
+	mac := encodeSum(s.Sum(s.sum[:0]))
+	putMAC(s, pool)
+	return mac
+}
+
+func normalizedTimestamp(ts int64) string {
+	return "hawk.1.ts\n" + strconv.FormatInt(ts, 10) + "\n"
+}
+
+// hashTimestamp calculates the MAC (tsm) sent with a server timestamp.
+func hashTimestamp(h crypto.Hash, k []byte, ts int64) string {
+	return macString(h, k, []byte(normalizedTimestamp(ts)))
 }
 
 // Finalize calculates and sets Hawk message authentication code (MAC).
 func (h *Hawk) Finalize(key []byte) bool {
+	return h.FinalizeSigner(Credentials{Key: key, Algorithm: h.algorithm}.Signer()) == nil
+}
+
+// FinalizeSigner is Finalize with the MAC calculated by s, which must use
+// the algorithm of h.
+func (h *Hawk) FinalizeSigner(s Signer) error {
 	if h.timestamp == 0 || h.nonce == "" || h.method == "" || h.uri == "" || h.host == "" || h.port == "" || h.reqMAC != "" {
-		return false
+		return ErrCannotFinalize
 	}
-	h.reqMAC = hashMAC(h.algorithm, key, h.timestamp, h.nonce, h.method, h.uri, h.host, h.port, h.reqHash, h.reqExt)
-	return true
+	if s.Algorithm() != h.algorithm {
+		return ErrAlgorithmMismatch
+	}
+	mac, err := signMAC(s, "header", h.timestamp, h.nonce, h.method, h.uri, h.host, h.port, h.reqHash, h.reqExt)
+	if err != nil {
+		return err
+	}
+	h.reqMAC = mac
+	return nil
 }
 
 // GetReqMAC returns the Hawk request MAC.
@@ -287,75 +377,276 @@ func (h *Hawk) GetAuthorization(uid string) string {
 	if h.reqMAC == "" {
 		return ""
 	}
-	hc := fmt.Sprintf(`Hawk id="%s", ts="%d", nonce="%s"`, uid, h.timestamp, h.nonce)
+	var hc strings.Builder
+	hc.Grow(64 + len(uid) + len(h.nonce) + len(h.reqHash) + len(h.reqExt) + len(h.reqMAC))
+	hc.WriteString(`Hawk id="`)
+	hc.WriteString(uid)
+	hc.WriteString(`", ts="`)
+	var ts [20]byte
+	hc.Write(strconv.AppendInt(ts[:0], h.timestamp, 10))
+	hc.WriteString(`", nonce="`)
+	hc.WriteString(h.nonce)
 	if h.reqHash != "" {
-		hc = fmt.Sprintf(`%s, hash="%s"`, hc, h.reqHash)
+		hc.WriteString(`", hash="`)
+		hc.WriteString(h.reqHash)
 	}
 	if h.reqExt != "" {
-		hc = fmt.Sprintf(`%s, ext="%s"`, hc, h.reqExt)
+		hc.WriteString(`", ext="`)
+		hc.WriteString(h.reqExt)
 	}
-	hc = fmt.Sprintf(`%s, mac="%s"`, hc, h.reqMAC)
-	return hc
+	hc.WriteString(`", mac="`)
+	hc.WriteString(h.reqMAC)
+	hc.WriteString(`"`)
+	return hc.String()
+}
+
+// parseURL splits an HTTP/HTTPS URL into host, port and URI, defaulting the
+// port from the scheme.
+func parseURL(url string) (string, string, string, error) {
+	pURL := urlRe.FindStringSubmatch(url)
+	if len(pURL) == 0 {
+		return "", "", "", fmt.Errorf("Failed to parse URL: %s", url)
+	}
+
+	var port string
+	if pURL[3] != "" {
+		port = pURL[4]
+	} else if pURL[1] == "https" {
+		port = "443"
+	} else if pURL[1] == "http" {
+		port = "80"
+	}
+	return pURL[2], port, pURL[5], nil
 }
 
 // NewRequest creates a new HTTP request with preset Content-Type header and
 // Authorization header for Hawk.
 func (c *Client) NewRequest(method string, url string, body io.Reader, contentType string, ext string) (*http.Request, error) {
-	req, err := http.NewRequest(method, url, body)
+	return c.NewRequestWithContext(context.Background(), method, url, body, contentType, ext)
+}
+
+// NewRequestWithContext is NewRequest with a context for the request.
+func (c *Client) NewRequestWithContext(ctx context.Context, method string, url string, body io.Reader, contentType string, ext string) (*http.Request, error) {
+	url = c.resolve(url)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return req, err
 	}
-	re := regexp.MustCompile(urlPattern)
-	pURL := re.FindSubmatch([]byte(url))
-	if len(pURL) == 0 {
-		return nil, fmt.Errorf("Failed to parse URL: %s", url)
+	if _, _, _, err := parseURL(url); err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", contentType)
+	h, s, err := c.sign(req, ext)
+	if err != nil {
+		return nil, err
 	}
+	c.setLast(h, s)
+	return req, nil
+}
 
-	var port string
-	if string(pURL[3]) != "" {
-		port = string(pURL[4])
-	} else if string(pURL[1]) == "https" {
-		port = "443"
-	} else if string(pURL[1]) == "http" {
-		port = "80"
+// resolve prepends BaseURL to url unless url is absolute.
+func (c *Client) resolve(url string) string {
+	if c.BaseURL == "" || strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return url
 	}
+	return strings.TrimSuffix(c.BaseURL, "/") + "/" + strings.TrimPrefix(url, "/")
+}
 
-	ts := time.Now().Unix()
-	nonce := NewNonce(c.NonceLength)
+// Sign sets the Hawk Authorization header of an existing HTTP request. The
+// payload is hashed if the request has a Content-Type, in which case the
+// body is read and restored. The returned Hawk can be used to validate the
+// response.
+func (c *Client) Sign(req *http.Request, ext string) (Hawk, error) {
+	h, _, err := c.sign(req, ext)
+	return h, err
+}
 
+func (c *Client) sign(req *http.Request, ext string) (Hawk, Signer, error) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return Hawk{}, nil, fmt.Errorf("Failed to parse URL: %s", req.URL)
+	}
+	uid, signer, err := c.signerFor(req.Context())
+	if err != nil {
+		return Hawk{}, nil, err
+	}
+	return c.signWith(req, ext, uid, signer)
+}
+
+// signWith is sign with the id uid and Signer signer.
+func (c *Client) signWith(req *http.Request, ext string, uid string, signer Signer) (Hawk, Signer, error) {
+	var err error
+	if c.ContentEncoding == HashWire && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	host, port := targetHostPort(req)
+
+	contentType := req.Header.Get("Content-Type")
 	var content []byte
-	if req.Body != nil {
-		body, _ = req.GetBody()
-		content, _ = ioutil.ReadAll(body)
+	if contentType != "" && req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			content, err = ioutil.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return Hawk{}, nil, err
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(content))
+			req.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(content)), nil
+			}
+		} else {
+			body, err := req.GetBody()
+			if err != nil {
+				return Hawk{}, nil, err
+			}
+			content, err = ioutil.ReadAll(body)
+			if err != nil {
+				return Hawk{}, nil, err
+			}
+		}
 	}
 
 	hd := Details{
-		Algorithm:   c.hash,
-		Host:        string(pURL[2]),
+		Algorithm:   signer.Algorithm(),
+		Host:        host,
 		Port:        port,
-		URI:         string(pURL[5]),
+		URI:         req.URL.RequestURI(),
 		ContentType: contentType,
 		Content:     content,
-		Method:      method,
-		Timestamp:   ts,
-		Nonce:       nonce,
-		Ext:         ext}
-	c.hawk, _ = hd.Create()
-	c.hawk.Validate()
-	c.hawk.Finalize(c.key)
-	auth := c.hawk.GetAuthorization(c.uid)
-	req.Header.Add("Content-Type", contentType)
-	req.Header.Add("Authorization", auth)
-	return req, nil
+		Method:      req.Method,
+		Timestamp:   time.Now().Unix(),
+		Nonce:       NewNonce(c.NonceLength),
+		Ext:         c.ext(ext)}
+	h, err := hd.Create()
+	if err != nil {
+		return Hawk{}, nil, err
+	}
+	h.Validate()
+	if err := h.FinalizeSigner(signer); err != nil {
+		return Hawk{}, nil, err
+	}
+	h.encoding = c.ContentEncoding
+	req.Header.Set("Authorization", h.GetAuthorization(uid))
+	return h, signer, nil
+}
+
+// targetHostPort returns the host and port a client request is sent to,
+// defaulting the port from the scheme.
+func targetHostPort(req *http.Request) (string, string) {
+	hostport := req.Host
+	if hostport == "" {
+		hostport = req.URL.Host
+	}
+	host, port, err := net.SplitHostPort(hostport)
+	if err == nil {
+		return host, port
+	}
+	if req.URL.Scheme == "https" {
+		return hostport, "443"
+	}
+	return hostport, "80"
+}
+
+// Authorization creates the value of a Hawk Authorization header for a
+// request without payload validation, for use with transports other than
+// net/http.
+func (c *Client) Authorization(method string, host string, port string, uri string, ext string) (string, error) {
+	return c.AuthorizationContext(context.Background(), method, host, port, uri, ext)
+}
+
+// AuthorizationContext is Authorization with a context that is passed on to
+// the Refresh function or CredentialProvider of the client.
+func (c *Client) AuthorizationContext(ctx context.Context, method string, host string, port string, uri string, ext string) (string, error) {
+	uid, signer, err := c.signerFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	hd := Details{
+		Algorithm: signer.Algorithm(),
+		Host:      host,
+		Port:      port,
+		URI:       uri,
+		Method:    method,
+		Nonce:     NewNonce(c.NonceLength),
+		Ext:       c.ext(ext)}
+	h, err := hd.Create()
+	if err != nil {
+		return "", err
+	}
+	if err := h.FinalizeSigner(signer); err != nil {
+		return "", err
+	}
+	return h.GetAuthorization(uid), nil
+}
+
+// credentials returns the credentials to sign with, retrieving them from
+// the provider if the client has one.
+func (c *Client) credentials(ctx context.Context) (Credentials, error) {
+	if c.provider != nil {
+		return c.provider.Retrieve(ctx)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Credentials{ID: c.uid, Key: c.key, Algorithm: c.hash}, nil
+}
+
+// signerFor returns the id and Signer to sign with, refusing credentials
+// that are no longer valid.
+func (c *Client) signerFor(ctx context.Context) (string, Signer, error) {
+	c.mu.Lock()
+	uid, signer, creds := c.uid, c.signer, c.creds
+	c.mu.Unlock()
+	if signer != nil {
+		if err := creds.Valid(time.Now()); err != nil {
+			if c.Refresh == nil {
+				return "", nil, err
+			}
+			return c.refresh(ctx)
+		}
+		return uid, signer, nil
+	}
+	creds, err := c.credentials(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := creds.Valid(time.Now()); err != nil {
+		return "", nil, err
+	}
+	return creds.ID, creds.Signer(), nil
+}
+
+// ext returns ext, or Client.Ext if ext is empty.
+func (c *Client) ext(ext string) string {
+	if ext == "" {
+		return c.Ext
+	}
+	return ext
 }
 
 // ValidateResponse validates the response to a Hawk request for message
 // authenticity, and if hash is sent: payload verification.
 func (c *Client) ValidateResponse(r http.Response) bool {
-	return c.hawk.ValidateResponse(c.key, r)
+	h, s := c.last()
+	if s == nil {
+		return false
+	}
+	return h.ValidateResponseSigner(s, r)
+}
+
+// setLast records h, signed by s, as the request last made by c.
+func (c *Client) setLast(h Hawk, s Signer) {
+	c.mu.Lock()
+	c.hawk, c.hawkSigner = h, s
+	c.mu.Unlock()
+}
+
+// last returns the request last made by c and its Signer.
+func (c *Client) last() (Hawk, Signer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hawk, c.hawkSigner
 }
 
 // NewClient creates a new Hawk client.
 func NewClient(uid string, key []byte, algorithm crypto.Hash, nonceLength int) Client {
-	return Client{uid: uid, key: key, hash: algorithm, NonceLength: nonceLength}
+	return Client{uid: uid, key: key, hash: algorithm, signer: keySigner{key: key, hash: algorithm}, NonceLength: nonceLength}
 }
@@ -2,6 +2,7 @@ package hawk
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"fmt"
 	"io/ioutil"
@@ -107,7 +108,7 @@ func TestValidateResponse(t *testing.T) {
 
 	t.Run("ok", func(t *testing.T) {
 		header := http.Header{}
-		header.Add("Server-Authorization", `Hawk mac="w0o3mOz86b7a1M6OGS2hfMlrYeVB0jz/O+nhC9oCUAI=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="response-specific"`)
+		header.Add("Server-Authorization", `Hawk mac="ByjtDxJPtv2QW5OLXgTApOeVLJKKEanC9/nYp55SmIc=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="response-specific"`)
 		header.Add("Content-Type", "text/plain")
 		resp := http.Response{
 			Status:     "200 OK",
@@ -123,7 +124,7 @@ func TestValidateResponse(t *testing.T) {
 	})
 	t.Run("ok-with-content-type-parameter", func(t *testing.T) {
 		header := http.Header{}
-		header.Add("Server-Authorization", `Hawk mac="w0o3mOz86b7a1M6OGS2hfMlrYeVB0jz/O+nhC9oCUAI=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="response-specific"`)
+		header.Add("Server-Authorization", `Hawk mac="ByjtDxJPtv2QW5OLXgTApOeVLJKKEanC9/nYp55SmIc=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="response-specific"`)
 		header.Add("Content-Type", "text/plain; charset=utf-8")
 		resp := http.Response{
 			Status:     "200 OK",
@@ -137,9 +138,25 @@ func TestValidateResponse(t *testing.T) {
 			t.Errorf("ValidateResponse failed:\n  got:  %t\n  want: %t", got, want)
 		}
 	})
+	t.Run("ok-with-content-type-case", func(t *testing.T) {
+		header := http.Header{}
+		header.Add("Server-Authorization", `Hawk mac="ByjtDxJPtv2QW5OLXgTApOeVLJKKEanC9/nYp55SmIc=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="response-specific"`)
+		header.Add("Content-Type", "Text/Plain ; charset=utf-8")
+		resp := http.Response{
+			Status:     "200 OK",
+			StatusCode: 200,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     header,
+			Body:       ioutil.NopCloser(bytes.NewBuffer([]byte("some reply")))}
+		if got, want := h.ValidateResponse([]byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"), resp), true; got != want {
+			t.Errorf("ValidateResponse failed:\n  got:  %t\n  want: %t", got, want)
+		}
+	})
 	t.Run("altered-payload", func(t *testing.T) {
 		header := http.Header{}
-		header.Add("Server-Authorization", `Hawk mac="w0o3mOz86b7a1M6OGS2hfMlrYeVB0jz/O+nhC9oCUAI=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="response-specific"`)
+		header.Add("Server-Authorization", `Hawk mac="ByjtDxJPtv2QW5OLXgTApOeVLJKKEanC9/nYp55SmIc=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="response-specific"`)
 		header.Add("Content-Type", "text/plain")
 		resp := http.Response{
 			Status:     "200 OK",
@@ -155,7 +172,7 @@ func TestValidateResponse(t *testing.T) {
 	})
 	t.Run("wrong-key", func(t *testing.T) {
 		header := http.Header{}
-		header.Add("Server-Authorization", `Hawk mac="w0o3mOz86b7a1M6OGS2hfMlrYeVB0jz/O+nhC9oCUAI=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="response-specific"`)
+		header.Add("Server-Authorization", `Hawk mac="ByjtDxJPtv2QW5OLXgTApOeVLJKKEanC9/nYp55SmIc=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="response-specific"`)
 		header.Add("Content-Type", "text/plain")
 		resp := http.Response{
 			Status:     "200 OK",
@@ -180,7 +197,7 @@ func TestCreate(t *testing.T) {
 			Method: "POST",
 		}
 		_, err := hd.Create()
-		if err != nil {
+		if err == nil {
 			t.Errorf("Create failed: no error on Details empty algorithm")
 		}
 	})
@@ -192,7 +209,7 @@ func TestCreate(t *testing.T) {
 			Method:    "POST",
 		}
 		_, err := hd.Create()
-		if err != nil {
+		if err == nil {
 			t.Errorf("Create failed: no error on Details empty host")
 		}
 	})
@@ -204,7 +221,7 @@ func TestCreate(t *testing.T) {
 			Method:    "POST",
 		}
 		_, err := hd.Create()
-		if err != nil {
+		if err == nil {
 			t.Errorf("Create failed: no error on Details empty port")
 		}
 	})
@@ -216,7 +233,7 @@ func TestCreate(t *testing.T) {
 			Method:    "POST",
 		}
 		_, err := hd.Create()
-		if err != nil {
+		if err == nil {
 			t.Errorf("Create failed: no error on Details empty uri")
 		}
 	})
@@ -228,10 +245,23 @@ func TestCreate(t *testing.T) {
 			URI:       "/resource/1?b=1&a=2",
 		}
 		_, err := hd.Create()
-		if err != nil {
+		if err == nil {
 			t.Errorf("Create failed: no error on Details empty method")
 		}
 	})
+	t.Run("Create-unregistered-Details-alg", func(t *testing.T) {
+		hd := Details{
+			Algorithm: crypto.MD5,
+			Host:      "example.com",
+			Port:      "8000",
+			URI:       "/resource/1?b=1&a=2",
+			Method:    "POST",
+		}
+		_, err := hd.Create()
+		if got, want := err, ErrUnknownAlgorithm; got != want {
+			t.Errorf("Create failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
 }
 
 func TestEmpty(t *testing.T) {
@@ -321,6 +351,41 @@ func TestClient(t *testing.T) {
 			t.Errorf("NewRequest failed: header Authorization empty")
 		}
 	})
+	t.Run("with-context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		h := NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		req, err := h.NewRequestWithContext(ctx, "GET", "http://localhost/hello", nil, "text/plain", "")
+		if err != nil {
+			t.Fatalf("NewRequestWithContext failed: %s", err.Error())
+		}
+		if req.Context() != ctx {
+			t.Errorf("NewRequestWithContext failed: context not set")
+		}
+	})
+	t.Run("sign", func(t *testing.T) {
+		h := NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		req := httptest.NewRequest("PUT", "https://localhost:8443/hello?a=1", strings.NewReader("Hello world!"))
+		req.Header.Set("Content-Type", "text/plain")
+		hk, err := h.Sign(req, "some-ext")
+		if err != nil {
+			t.Fatalf("Sign failed: %s", err.Error())
+		}
+		if hk.GetReqHash() == "" {
+			t.Errorf("Sign failed: no payload hash")
+		}
+		v := &Verifier{Credentials: CredentialMap{"jdoe": {ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256}}}
+		if _, _, err := v.Verify(req); err != nil {
+			t.Errorf("Verify failed: %s", err.Error())
+		}
+	})
+	t.Run("sign-unknown-scheme", func(t *testing.T) {
+		h := NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		req, _ := http.NewRequest("GET", "ftp://localhost/hello", nil)
+		if _, err := h.Sign(req, ""); err == nil {
+			t.Errorf("Sign failed: no error on unsupported scheme")
+		}
+	})
 	t.Run("send-no-data", func(t *testing.T) {
 		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "text/plain")
@@ -360,3 +425,52 @@ func TestClient(t *testing.T) {
 		}
 	})
 }
+
+func TestContentType(t *testing.T) {
+	cases := []struct {
+		name string
+		ct   string
+		want string
+	}{
+		{"plain", "text/plain", "text/plain"},
+		{"parameter", "text/plain; charset=utf-8", "text/plain"},
+		{"parameter-no-space", "text/plain;x=y", "text/plain"},
+		{"case", "Text/Plain", "text/plain"},
+		{"whitespace", "  text/plain ; charset=utf-8", "text/plain"},
+		{"empty", "", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := normalizeContentType(c.ct); got != c.want {
+				t.Errorf("normalizeContentType failed:\n  got:  %q\n  want: %q", got, c.want)
+			}
+		})
+	}
+	// Vectors from the reference implementation's client tests.
+	t.Run("interop-sha256", func(t *testing.T) {
+		for _, ct := range []string{"text/plain", "text/plain;x=y", "Text/Plain; charset=utf-8"} {
+			hd := Details{
+				Algorithm:   crypto.SHA256,
+				Host:        "example.net",
+				Port:        "443",
+				URI:         "/somewhere/over/the/rainbow",
+				Method:      "POST",
+				ContentType: ct,
+				Content:     []byte("something to write about"),
+				Timestamp:   1353809207,
+				Nonce:       "Ygvqdz",
+				Ext:         "Bazinga!"}
+			h, _ := hd.Create()
+			h.Validate()
+			h.Finalize([]byte("2983d45yun89q"))
+			if got, want := h.GetAuthorization("123456"), `Hawk id="123456", ts="1353809207", nonce="Ygvqdz", hash="2QfCt3GuY9HQnHWyWD3wX68ZOKbynqlfYmuO2ZBRqtY=", ext="Bazinga!", mac="q1CwFoSHzPZSkbIvl0oYlD+91rBUEvFk763nMjMndj8="`; got != want {
+				t.Errorf("GetAuthorization failed for %q:\n  got:  %s\n  want: %s", ct, got, want)
+			}
+		}
+	})
+	t.Run("interop-sha1-no-content-type", func(t *testing.T) {
+		if got, want := hashPayload(crypto.SHA1, "", []byte("something to write about")), "bsvY3IfUllw6V5rvk4tStEvpBhE="; got != want {
+			t.Errorf("hashPayload failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+}
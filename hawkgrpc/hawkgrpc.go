@@ -0,0 +1,183 @@
+// Package hawkgrpc provides gRPC interceptors for Hawk authentication.
+//
+// Calls are signed as a POST of the full method name, e.g.
+// "/helloworld.Greeter/SayHello", to the host and port of the :authority.
+// The Authorization is carried in the call metadata. Payloads are not
+// hashed.
+//
+// The client interceptors derive the :authority from the dial target. If
+// it is overridden, with grpc.WithAuthority or by the name resolver, pass
+// the authority the server sees with WithAuthority.
+//
+//     hc := hawk.NewClient("your-hawk-id", []byte("secret"), crypto.SHA256, 6)
+//     conn, err := grpc.Dial("example.com:443",
+//         grpc.WithUnaryInterceptor(hawkgrpc.UnaryClientInterceptor(&hc)),
+//         grpc.WithStreamInterceptor(hawkgrpc.StreamClientInterceptor(&hc)))
+//
+//     v := &hawk.Verifier{Credentials: creds}
+//     s := grpc.NewServer(
+//         grpc.UnaryInterceptor(hawkgrpc.UnaryServerInterceptor(v)),
+//         grpc.StreamInterceptor(hawkgrpc.StreamServerInterceptor(v)))
+package hawkgrpc
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	hawk "gitlab.com/tdely/go-hawk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Method is the HTTP method gRPC calls are signed with.
+const Method = "POST"
+
+// defaultPort is assumed when the authority carries no port.
+const defaultPort = "443"
+
+type credentialsKey struct{}
+
+// CredentialsFromContext returns the credentials a call was authenticated
+// with by one of the server interceptors.
+func CredentialsFromContext(ctx context.Context) (hawk.Credentials, bool) {
+	c, ok := ctx.Value(credentialsKey{}).(hawk.Credentials)
+	return c, ok
+}
+
+// ClientOption configures the client interceptors.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	authority string
+}
+
+// WithAuthority signs calls for authority, as "host:port", instead of the
+// authority derived from the dial target.
+func WithAuthority(authority string) ClientOption {
+	return func(o *clientOptions) {
+		o.authority = authority
+	}
+}
+
+func newClientOptions(opts []ClientOption) clientOptions {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// authorityOf returns the authority calls on cc are signed for.
+func (o clientOptions) authorityOf(cc *grpc.ClientConn) string {
+	if o.authority != "" {
+		return o.authority
+	}
+	return authority(cc.Target())
+}
+
+// UnaryClientInterceptor signs every unary call with the credentials of c.
+func UnaryClientInterceptor(c *hawk.Client, opts ...ClientOption) grpc.UnaryClientInterceptor {
+	o := newClientOptions(opts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := sign(ctx, c, o.authorityOf(cc), method)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor signs every streaming call with the credentials
+// of c when the stream is created.
+func StreamClientInterceptor(c *hawk.Client, opts ...ClientOption) grpc.StreamClientInterceptor {
+	o := newClientOptions(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := sign(ctx, c, o.authorityOf(cc), method)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// UnaryServerInterceptor rejects unary calls that do not carry a valid Hawk
+// Authorization with codes.Unauthenticated.
+func UnaryServerInterceptor(v *hawk.Verifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := verify(ctx, v, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streaming calls that do not carry a valid
+// Hawk Authorization with codes.Unauthenticated.
+func StreamServerInterceptor(v *hawk.Verifier) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := verify(ss.Context(), v, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func sign(ctx context.Context, c *hawk.Client, authority string, method string) (context.Context, error) {
+	host, port := splitAuthority(authority)
+	auth, err := c.AuthorizationContext(ctx, Method, host, port, method, "")
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", auth), nil
+}
+
+func verify(ctx context.Context, v *hawk.Verifier, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var auth, host, port string
+	if vals := md.Get("authorization"); len(vals) > 0 {
+		auth = vals[0]
+	}
+	if vals := md.Get(":authority"); len(vals) > 0 {
+		host, port = splitAuthority(vals[0])
+	}
+	_, creds, err := v.VerifyHeaderContext(ctx, auth, Method, method, host, port)
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return context.WithValue(ctx, credentialsKey{}, creds), nil
+}
+
+// authority returns the authority gRPC derives from a dial target, which is
+// the endpoint part of "scheme://authority/endpoint" targets.
+func authority(target string) string {
+	if i := strings.Index(target, "://"); i != -1 {
+		target = target[i+3:]
+		if j := strings.Index(target, "/"); j != -1 {
+			target = target[j+1:]
+		}
+	}
+	return target
+}
+
+func splitAuthority(a string) (string, string) {
+	host, port, err := net.SplitHostPort(a)
+	if err != nil {
+		return a, defaultPort
+	}
+	return host, port
+}
@@ -0,0 +1,173 @@
+package hawkgrpc
+
+import (
+	"context"
+	"crypto"
+	"net"
+	"testing"
+
+	hawk "gitlab.com/tdely/go-hawk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startServer runs a health service behind the Hawk server interceptors and
+// returns the id of the credentials the last unary call was verified with.
+func startServer(t *testing.T) (*bufconn.Listener, *string) {
+	lis := bufconn.Listen(1 << 20)
+	v := &hawk.Verifier{Credentials: hawk.CredentialMap{
+		"jdoe": {ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256},
+	}}
+	var id string
+	record := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		c, _ := CredentialsFromContext(ctx)
+		id = c.ID
+		return handler(ctx, req)
+	}
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(v), record),
+		grpc.StreamInterceptor(StreamServerInterceptor(v)))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis, &id
+}
+
+func dial(t *testing.T, lis *bufconn.Listener, opts ...grpc.DialOption) healthpb.HealthClient {
+	opts = append(opts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+	if err != nil {
+		t.Fatalf("Dial failed: %s", err.Error())
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func withClient(hc *hawk.Client, opts ...ClientOption) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(hc, opts...)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(hc, opts...))}
+}
+
+// callKey marks the context of a call for ctxProvider.
+type callKey struct{}
+
+// ctxProvider only hands out credentials to calls with callKey set, so that
+// signing with another context than the call's fails.
+type ctxProvider struct{}
+
+func (ctxProvider) Retrieve(ctx context.Context) (hawk.Credentials, error) {
+	if ctx.Value(callKey{}) == nil {
+		return hawk.Credentials{}, hawk.ErrUnknownCredentials
+	}
+	return hawk.Credentials{ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256}, nil
+}
+
+func TestUnary(t *testing.T) {
+	lis, id := startServer(t)
+	t.Run("ok", func(t *testing.T) {
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		c := dial(t, lis, withClient(&hc)...)
+		if _, err := c.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("Check failed: %s", err.Error())
+		}
+		if got, want := *id, "jdoe"; got != want {
+			t.Errorf("CredentialsFromContext failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("wrong-key", func(t *testing.T) {
+		hc := hawk.NewClient("jdoe", []byte("wrong"), crypto.SHA256, 6)
+		c := dial(t, lis, withClient(&hc)...)
+		_, err := c.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if got, want := status.Code(err), codes.Unauthenticated; got != want {
+			t.Errorf("Check failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+	t.Run("authority", func(t *testing.T) {
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		c := dial(t, lis, append(withClient(&hc, WithAuthority("api.example.com:8443")), grpc.WithAuthority("api.example.com:8443"))...)
+		if _, err := c.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("Check failed: %s", err.Error())
+		}
+		c = dial(t, lis, append(withClient(&hc), grpc.WithAuthority("api.example.com:8443"))...)
+		_, err := c.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if got, want := status.Code(err), codes.Unauthenticated; got != want {
+			t.Errorf("Check without WithAuthority failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+	t.Run("context", func(t *testing.T) {
+		hc := hawk.NewClientWithProvider(ctxProvider{}, 6)
+		c := dial(t, lis, withClient(&hc)...)
+		ctx := context.WithValue(context.Background(), callKey{}, true)
+		if _, err := c.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("Check failed: %s", err.Error())
+		}
+		_, err := c.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if got, want := status.Code(err), codes.Unauthenticated; got != want {
+			t.Errorf("Check without call context failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+	t.Run("unsigned", func(t *testing.T) {
+		c := dial(t, lis)
+		_, err := c.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if got, want := status.Code(err), codes.Unauthenticated; got != want {
+			t.Errorf("Check failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+}
+
+func TestStream(t *testing.T) {
+	lis, _ := startServer(t)
+	t.Run("ok", func(t *testing.T) {
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		c := dial(t, lis, withClient(&hc)...)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stream, err := c.Watch(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("Watch failed: %s", err.Error())
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Errorf("Recv failed: %s", err.Error())
+		}
+	})
+	t.Run("unsigned", func(t *testing.T) {
+		c := dial(t, lis)
+		stream, err := c.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("Watch failed: %s", err.Error())
+		}
+		_, err = stream.Recv()
+		if got, want := status.Code(err), codes.Unauthenticated; got != want {
+			t.Errorf("Recv failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+}
+
+func TestAuthority(t *testing.T) {
+	cases := []struct {
+		target string
+		host   string
+		port   string
+	}{
+		{"example.com:8443", "example.com", "8443"},
+		{"example.com", "example.com", "443"},
+		{"dns:///example.com:50051", "example.com", "50051"},
+		{"passthrough:///bufnet", "bufnet", "443"},
+	}
+	for _, c := range cases {
+		host, port := splitAuthority(authority(c.target))
+		if host != c.host || port != c.port {
+			t.Errorf("authority failed for %s:\n  got:  %s %s\n  want: %s %s", c.target, host, port, c.host, c.port)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package hawksql provides Hawk credential and nonce stores backed by a
+// database/sql database, so that replicas of a server share credentials and
+// replay detection. The queries use $n placeholders and ON CONFLICT, as
+// understood by PostgreSQL 9.5 and SQLite 3.24 or later.
+//
+// The stores expect the tables of Schema, optionally under other names:
+//
+//     db, err := sql.Open("pgx", dsn)
+//     v := &hawk.Verifier{
+//         Credentials: &hawksql.CredentialStore{DB: db},
+//         Nonces:      &hawksql.NonceStore{DB: db},
+//     }
+package hawksql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	hawk "gitlab.com/tdely/go-hawk"
+)
+
+// Default table names.
+const (
+	CredentialTable = "hawk_credentials"
+	NonceTable      = "hawk_nonces"
+)
+
+// Schema creates the tables with their default names.
+//
+// An id may have several keys for rotation. Requests are accepted with any
+// key valid at the time of the request, so a new key is rolled out by
+// inserting it with not_before set to when clients may switch to it, and
+// the old one retired by setting its not_after, once clients have, or
+// revoked. Keys used for signing are the valid ones with the latest
+// not_before.
+// not_before and not_after are Unix times; NULL leaves the key valid since
+// or until forever. The algorithm is a name registered with hawk.RegisterAlgorithm.
+const Schema = `
+CREATE TABLE hawk_credentials (
+	id         TEXT NOT NULL,
+	key        TEXT NOT NULL,
+	algorithm  TEXT NOT NULL DEFAULT 'sha256',
+	not_before BIGINT,
+	not_after  BIGINT,
+	revoked    BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX hawk_credentials_id ON hawk_credentials (id);
+
+CREATE TABLE hawk_nonces (
+	id    TEXT NOT NULL,
+	nonce TEXT NOT NULL,
+	ts    BIGINT NOT NULL,
+	PRIMARY KEY (id, nonce)
+);
+CREATE INDEX hawk_nonces_ts ON hawk_nonces (ts);
+`
+
+// CredentialStore is a hawk.CredentialStore that looks up credentials in
+// a table of Schema.
+type CredentialStore struct {
+	DB *sql.DB
+	// Table is the name of the credential table, CredentialTable if empty.
+	Table string
+	// Now returns the time keys must be valid at, time.Now if nil.
+	Now func() time.Time
+
+	once  sync.Once
+	query string
+}
+
+// Lookup returns the credentials of id with the key currently valid, or
+// the one with the latest not_before of several. If no key is valid, the
+// credentials with the latest not_before are returned for the Verifier to
+// reject as expired, revoked or not yet valid. Ids without keys give
+// hawk.ErrUnknownCredentials.
+func (s *CredentialStore) Lookup(ctx context.Context, id string) (hawk.Credentials, error) {
+	all, err := s.LookupAll(ctx, id)
+	if err != nil {
+		return hawk.Credentials{}, err
+	}
+	return all[0], nil
+}
+
+// LookupAll returns the credentials of id with every key currently valid,
+// latest not_before first, so that a Verifier accepts both the old and the
+// new key while they overlap. If no key is valid it returns what Lookup
+// does.
+func (s *CredentialStore) LookupAll(ctx context.Context, id string) ([]hawk.Credentials, error) {
+	s.once.Do(func() {
+		table := s.Table
+		if table == "" {
+			table = CredentialTable
+		}
+		s.query = `SELECT key, algorithm, not_before, not_after, revoked FROM ` + table +
+			` WHERE id = $1 ORDER BY COALESCE(not_before, 0) DESC`
+	})
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now()
+	rows, err := s.DB.QueryContext(ctx, s.query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var valid []hawk.Credentials
+	var first *hawk.Credentials
+	var firstAlg string
+	for rows.Next() {
+		var c hawk.Credentials
+		var name string
+		var notBefore, notAfter sql.NullInt64
+		if err := rows.Scan(&c.Key, &name, &notBefore, &notAfter, &c.Revoked); err != nil {
+			return nil, err
+		}
+		c.ID = id
+		if notBefore.Valid {
+			c.NotBefore = time.Unix(notBefore.Int64, 0)
+		}
+		if notAfter.Valid {
+			c.NotAfter = time.Unix(notAfter.Int64, 0)
+		}
+		if c.Valid(t) == nil {
+			if c, err = credentials(c, name); err != nil {
+				return nil, err
+			}
+			valid = append(valid, c)
+		} else if first == nil {
+			first, firstAlg = &c, name
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(valid) > 0 {
+		return valid, nil
+	}
+	if first == nil {
+		return nil, hawk.ErrUnknownCredentials
+	}
+	c, err := credentials(*first, firstAlg)
+	if err != nil {
+		return nil, err
+	}
+	return []hawk.Credentials{c}, nil
+}
+
+// credentials sets the algorithm of c from its name.
+func credentials(c hawk.Credentials, name string) (hawk.Credentials, error) {
+	alg, err := hawk.LookupAlgorithm(name)
+	if err != nil {
+		return hawk.Credentials{}, err
+	}
+	c.Algorithm = alg
+	return c, nil
+}
+
+// NonceStore is a hawk.NonceStore that records nonces in a table of Schema.
+// A nonce is accepted only if inserting it changes a row, which makes
+// replay detection atomic across replicas. Nonces are remembered for
+// Window, hawk.DefaultNonceWindow if zero; requests with older timestamps
+// are rejected with hawk.ErrStaleTimestamp.
+//
+// Expired nonces are deleted by Check about once per Window, or by Prune.
+type NonceStore struct {
+	DB *sql.DB
+	// Table is the name of the nonce table, NonceTable if empty.
+	Table  string
+	Window time.Duration
+
+	once   sync.Once
+	insert string
+	prune  string
+	pruned int64
+}
+
+func (s *NonceStore) init() {
+	table := s.Table
+	if table == "" {
+		table = NonceTable
+	}
+	// An expired nonce that has not been pruned yet is taken over rather
+	// than reported as replayed.
+	s.insert = `INSERT INTO ` + table + ` (id, nonce, ts) VALUES ($1, $2, $3)` +
+		` ON CONFLICT (id, nonce) DO UPDATE SET ts = excluded.ts WHERE ` + table + `.ts < $4`
+	s.prune = `DELETE FROM ` + table + ` WHERE ts < $1`
+}
+
+func (s *NonceStore) window() int64 {
+	if s.Window == 0 {
+		return int64(hawk.DefaultNonceWindow.Seconds())
+	}
+	return int64(s.Window.Seconds())
+}
+
+// Check records nonce for id and returns hawk.ErrReplayedNonce if it was
+// already used within the window.
+func (s *NonceStore) Check(ctx context.Context, id string, nonce string, ts int64) error {
+	s.once.Do(s.init)
+	now := time.Now().Unix()
+	oldest := now - s.window()
+	if ts < oldest {
+		return hawk.ErrStaleTimestamp
+	}
+	last := atomic.LoadInt64(&s.pruned)
+	if now-last >= s.window() && atomic.CompareAndSwapInt64(&s.pruned, last, now) {
+		if _, err := s.DB.ExecContext(ctx, s.prune, oldest); err != nil {
+			return err
+		}
+	}
+	res, err := s.DB.ExecContext(ctx, s.insert, id, nonce, ts, oldest)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return hawk.ErrReplayedNonce
+	}
+	return nil
+}
+
+// Prune deletes the nonces that have expired.
+func (s *NonceStore) Prune(ctx context.Context) error {
+	s.once.Do(s.init)
+	now := time.Now().Unix()
+	atomic.StoreInt64(&s.pruned, now)
+	_, err := s.DB.ExecContext(ctx, s.prune, now-s.window())
+	return err
+}
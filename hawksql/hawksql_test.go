@@ -0,0 +1,256 @@
+package hawksql
+
+import (
+	"context"
+	"crypto"
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	hawk "gitlab.com/tdely/go-hawk"
+	_ "modernc.org/sqlite"
+)
+
+// openDB returns an in-memory SQLite database with the tables of Schema.
+func openDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection would get a database of its own.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(Schema); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCredentialStoreLookup(t *testing.T) {
+	db := openDB(t)
+	rows := []struct {
+		id, key, alg        string
+		notBefore, notAfter interface{}
+	}{
+		{"jdoe", "old", "sha256", nil, int64(2000)},
+		{"jdoe", "current", "sha256", int64(1000), nil},
+		{"jdoe", "next", "sha512", int64(3000), nil},
+		{"asmith", "weird", "md5", nil, nil},
+	}
+	for _, r := range rows {
+		_, err := db.Exec(`INSERT INTO hawk_credentials (id, key, algorithm, not_before, not_after) VALUES ($1, $2, $3, $4, $5)`,
+			r.id, r.key, r.alg, r.notBefore, r.notAfter)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var now int64
+	s := &CredentialStore{DB: db, Now: func() time.Time { return time.Unix(now, 0) }}
+
+	cases := []struct {
+		now  int64
+		key  string
+		alg  crypto.Hash
+		err  error
+		name string
+	}{
+		{500, "old", crypto.SHA256, nil, "before rotation"},
+		{1500, "current", crypto.SHA256, nil, "overlap"},
+		{2500, "current", crypto.SHA256, nil, "after old expired"},
+		{3000, "next", crypto.SHA512, nil, "next valid"},
+	}
+	for _, c := range cases {
+		now = c.now
+		got, err := s.Lookup(context.Background(), "jdoe")
+		if err != c.err || string(got.Key) != c.key || got.Algorithm != c.alg || got.ID != "jdoe" {
+			t.Errorf("Lookup %s failed:\n  got:  %q %v %v\n  want: %q %v %v", c.name, got.Key, got.Algorithm, err, c.key, c.alg, c.err)
+		}
+	}
+
+	allCases := []struct {
+		now  int64
+		keys string
+		name string
+	}{
+		{500, "old", "before rotation"},
+		{1500, "current old", "overlap"},
+		{2500, "current", "after old expired"},
+		{2000, "current", "old expired"},
+	}
+	for _, c := range allCases {
+		now = c.now
+		all, err := s.LookupAll(context.Background(), "jdoe")
+		var keys []string
+		for _, creds := range all {
+			keys = append(keys, string(creds.Key))
+		}
+		if got := strings.Join(keys, " "); err != nil || got != c.keys {
+			t.Errorf("LookupAll %s failed:\n  got:  %q %v\n  want: %q %v", c.name, got, err, c.keys, nil)
+		}
+	}
+
+	now = 500
+	if _, err := db.Exec(`UPDATE hawk_credentials SET revoked = TRUE WHERE key = 'old'`); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Lookup(context.Background(), "jdoe")
+	if err != nil || string(got.Key) != "next" || got.Valid(time.Unix(now, 0)) != hawk.ErrCredentialsNotYetValid {
+		t.Errorf("Lookup revoked failed:\n  got:  %q %v\n  want: %q %v", got.Key, got.Valid(time.Unix(now, 0)), "next", hawk.ErrCredentialsNotYetValid)
+	}
+
+	if _, err := s.Lookup(context.Background(), "nobody"); err != hawk.ErrUnknownCredentials {
+		t.Errorf("Lookup unknown failed:\n  got:  %v\n  want: %v", err, hawk.ErrUnknownCredentials)
+	}
+	if _, err := s.Lookup(context.Background(), "asmith"); err != hawk.ErrUnknownAlgorithm {
+		t.Errorf("Lookup bad algorithm failed:\n  got:  %v\n  want: %v", err, hawk.ErrUnknownAlgorithm)
+	}
+}
+
+func TestCredentialStoreTable(t *testing.T) {
+	db := openDB(t)
+	if _, err := db.Exec(`ALTER TABLE hawk_credentials RENAME TO creds`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO creds (id, key) VALUES ('jdoe', 'secret')`); err != nil {
+		t.Fatal(err)
+	}
+	s := &CredentialStore{DB: db, Table: "creds"}
+	got, err := s.Lookup(context.Background(), "jdoe")
+	if err != nil || string(got.Key) != "secret" || got.Algorithm != crypto.SHA256 {
+		t.Errorf("Lookup failed:\n  got:  %q %v %v\n  want: %q %v", got.Key, got.Algorithm, err, "secret", crypto.SHA256)
+	}
+}
+
+func TestNonceStoreCheck(t *testing.T) {
+	db := openDB(t)
+	s := &NonceStore{DB: db}
+	ctx := context.Background()
+	now := time.Now().Unix()
+
+	cases := []struct {
+		id, nonce string
+		ts        int64
+		want      error
+	}{
+		{"jdoe", "abc", now, nil},
+		{"jdoe", "abc", now, hawk.ErrReplayedNonce},
+		{"jdoe", "abc", now + 1, hawk.ErrReplayedNonce},
+		{"asmith", "abc", now, nil},
+		{"jdoe", "def", now, nil},
+		{"jdoe", "old", now - 3600, hawk.ErrStaleTimestamp},
+	}
+	for _, c := range cases {
+		if got := s.Check(ctx, c.id, c.nonce, c.ts); got != c.want {
+			t.Errorf("Check %s %s failed:\n  got:  %v\n  want: %v", c.id, c.nonce, got, c.want)
+		}
+	}
+}
+
+func TestNonceStoreExpired(t *testing.T) {
+	db := openDB(t)
+	s := &NonceStore{DB: db}
+	ctx := context.Background()
+	now := time.Now().Unix()
+	// An expired nonce left behind, as by a replica that has not pruned.
+	if _, err := db.Exec(`INSERT INTO hawk_nonces (id, nonce, ts) VALUES ('jdoe', 'abc', $1), ('jdoe', 'def', $1)`, now-3600); err != nil {
+		t.Fatal(err)
+	}
+	s.pruned = now
+	if got := s.Check(ctx, "jdoe", "abc", now); got != nil {
+		t.Errorf("Check expired failed:\n  got:  %v\n  want: %v", got, nil)
+	}
+	if got := s.Check(ctx, "jdoe", "abc", now); got != hawk.ErrReplayedNonce {
+		t.Errorf("Check replay failed:\n  got:  %v\n  want: %v", got, hawk.ErrReplayedNonce)
+	}
+
+	if err := s.Prune(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM hawk_nonces`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("Prune failed:\n  got:  %v\n  want: %v", n, 1)
+	}
+}
+
+func TestNonceStoreConcurrent(t *testing.T) {
+	db := openDB(t)
+	// Replicas sharing the database.
+	stores := []*NonceStore{{DB: db}, {DB: db}, {DB: db}}
+	now := time.Now().Unix()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(s *NonceStore) {
+			defer wg.Done()
+			err := s.Check(context.Background(), "jdoe", "abc", now)
+			if err == nil {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			} else if err != hawk.ErrReplayedNonce {
+				t.Error(err)
+			}
+		}(stores[i%len(stores)])
+	}
+	wg.Wait()
+	if accepted != 1 {
+		t.Errorf("Check failed:\n  got:  %v accepted\n  want: %v accepted", accepted, 1)
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	db := openDB(t)
+	if _, err := db.Exec(`INSERT INTO hawk_credentials (id, key, algorithm) VALUES ('jdoe', 'Syp9393', 'sha256')`); err != nil {
+		t.Fatal(err)
+	}
+	v := &hawk.Verifier{Credentials: &CredentialStore{DB: db}, Nonces: &NonceStore{DB: db}}
+	c := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+	req, err := c.NewRequest("GET", "http://example.com/resource", nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := req.Header.Get("Authorization")
+	if _, _, err := v.VerifyHeader(auth, "GET", "/resource", "example.com", "80"); err != nil {
+		t.Errorf("VerifyHeader failed:\n  got:  %v\n  want: %v", err, nil)
+	}
+	if _, _, err := v.VerifyHeader(auth, "GET", "/resource", "example.com", "80"); err != hawk.ErrReplayedNonce {
+		t.Errorf("VerifyHeader replay failed:\n  got:  %v\n  want: %v", err, hawk.ErrReplayedNonce)
+	}
+}
+
+func TestVerifierRotation(t *testing.T) {
+	db := openDB(t)
+	_, err := db.Exec(`INSERT INTO hawk_credentials (id, key, not_before, not_after) VALUES ('jdoe', 'old', NULL, 2000), ('jdoe', 'current', 1000, NULL)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &hawk.Verifier{
+		Credentials: &CredentialStore{DB: db, Now: func() time.Time { return time.Unix(1500, 0) }},
+		Now:         func() time.Time { return time.Unix(1500, 0) },
+	}
+	cases := []struct {
+		key   string
+		nonce string
+		err   error
+	}{
+		{"old", "abc", nil},
+		{"current", "def", nil},
+		{"other", "ghi", hawk.ErrInvalidMAC},
+	}
+	for _, c := range cases {
+		hd := hawk.Details{Algorithm: crypto.SHA256, Host: "example.com", Port: "80", URI: "/resource", Method: "GET", Timestamp: 1500, Nonce: c.nonce}
+		h, _ := hd.Create()
+		h.Finalize([]byte(c.key))
+		_, got, err := v.VerifyHeader(h.GetAuthorization("jdoe"), "GET", "/resource", "example.com", "80")
+		if err != c.err || (err == nil && string(got.Key) != c.key) {
+			t.Errorf("VerifyHeader %s failed:\n  got:  %q %v\n  want: %q %v", c.key, got.Key, err, c.key, c.err)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package hawktest provides a fake Hawk server for testing clients.
+//
+// The server verifies the Hawk Authorization of every request against the
+// credentials registered with it, records what it received and signs its
+// responses. It can be told to tamper with the response signature, or to
+// run with a skewed clock so that requests get stale timestamp challenges:
+//
+//     s := hawktest.NewServer(nil)
+//     defer s.Close()
+//     s.AddCredentials(hawk.Credentials{ID: "jdoe", Key: []byte("secret"), Algorithm: crypto.SHA256})
+//     s.SetResponseMode(hawktest.TamperMAC)
+//
+//     hc := hawk.NewClient("jdoe", []byte("secret"), crypto.SHA256, 6)
+//     req, _ := hc.NewRequest("GET", s.URL+"/resource", nil, "", "")
+//     resp, _ := http.DefaultClient.Do(req)
+//     valid := hc.ValidateResponse(*resp) // false
+//     r := s.Requests()[0]
+package hawktest
+
+import (
+	"bytes"
+	"crypto"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	hawk "gitlab.com/tdely/go-hawk"
+)
+
+// ResponseMode controls how the server signs its responses.
+type ResponseMode int
+
+// Response modes.
+const (
+	// Sign responses with a valid Server-Authorization header.
+	Sign ResponseMode = iota
+	// NoSignature sends responses without Server-Authorization.
+	NoSignature
+	// TamperMAC sends a Server-Authorization with an invalid MAC.
+	TamperMAC
+	// TamperHash sends a Server-Authorization with a payload hash that
+	// does not match the body.
+	TamperHash
+)
+
+// Request is what the server received with a single request.
+type Request struct {
+	// Attributes of the Authorization header.
+	ID    string
+	Ts    int64
+	Nonce string
+	Hash  string
+	Ext   string
+	MAC   string
+
+	Method      string
+	URI         string
+	Host        string
+	Port        string
+	ContentType string
+	Payload     []byte
+
+	// Err is the verification error, nil if the request was authenticated.
+	Err error
+}
+
+// Server is a fake Hawk server.
+type Server struct {
+	*httptest.Server
+
+	handler http.Handler
+
+	mu          sync.Mutex
+	credentials hawk.CredentialMap
+	algorithms  []crypto.Hash
+	nonces      *hawk.MemoryNonceStore
+	requests    []Request
+	mode        ResponseMode
+	ext         string
+	offset      time.Duration
+}
+
+// NewServer starts a fake Hawk server that passes authenticated requests
+// to handler. A nil handler responds with "OK.". The caller should call
+// Close when finished.
+func NewServer(handler http.Handler) *Server {
+	s := newServer(handler)
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer is NewServer using TLS.
+func NewTLSServer(handler http.Handler) *Server {
+	s := newServer(handler)
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+func newServer(handler http.Handler) *Server {
+	if handler == nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("OK."))
+		})
+	}
+	return &Server{
+		handler:     handler,
+		credentials: hawk.CredentialMap{},
+		nonces:      &hawk.MemoryNonceStore{}}
+}
+
+// AddCredentials registers credentials the server accepts.
+func (s *Server) AddCredentials(c hawk.Credentials) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.credentials[c.ID] = c
+}
+
+// SetAlgorithms sets the algorithms the server accepts, by default
+// hawk.DefaultAlgorithms.
+func (s *Server) SetAlgorithms(algs ...crypto.Hash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.algorithms = algs
+}
+
+// SetResponseMode sets how responses are signed.
+func (s *Server) SetResponseMode(m ResponseMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mode = m
+}
+
+// SetResponseExt sets the ext sent in Server-Authorization.
+func (s *Server) SetResponseExt(ext string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ext = ext
+}
+
+// SetClockOffset skews the server clock by d. Requests whose timestamps
+// fall outside the allowed skew of the skewed clock are answered with a
+// stale timestamp challenge carrying the server time.
+func (s *Server) SetClockOffset(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = d
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset forgets the received requests and seen nonces.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.nonces = &hawk.MemoryNonceStore{}
+}
+
+// ServeHTTP verifies and records r, and passes it on to the handler if it
+// is authenticated.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	offset, mode, ext := s.offset, s.mode, s.ext
+	creds := hawk.CredentialMap{}
+	for id, c := range s.credentials {
+		creds[id] = c
+	}
+	v := &hawk.Verifier{
+		Credentials: creds,
+		Nonces:      s.nonces,
+		Algorithms:  s.algorithms,
+		Now:         func() time.Time { return time.Now().Add(offset) }}
+	s.mu.Unlock()
+
+	rec := record(r)
+	h, c, err := v.Verify(r)
+	rec.Err = err
+	s.mu.Lock()
+	s.requests = append(s.requests, rec)
+	s.mu.Unlock()
+	if err == hawk.ErrStaleTimestamp {
+		if challenge, cerr := v.TimestampChallenge(c); cerr == nil {
+			w.Header().Set("WWW-Authenticate", challenge)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Hawk error="`+err.Error()+`"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	rw := httptest.NewRecorder()
+	s.handler.ServeHTTP(rw, r)
+	resp := rw.Result()
+	body := rw.Body.Bytes()
+	for k, vs := range resp.Header {
+		w.Header()[k] = vs
+	}
+	if mode != NoSignature {
+		signed := body
+		if mode == TamperHash {
+			signed = append([]byte("tampered "), body...)
+		}
+		auth := h.GetServerAuthorization(c.Key, resp.Header.Get("Content-Type"), signed, ext)
+		if mode == TamperMAC {
+			auth = macRe.ReplaceAllString(auth, `mac="AAAA$1"`)
+		}
+		w.Header().Set("Server-Authorization", auth)
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(body)
+}
+
+var (
+	attrRe = regexp.MustCompile(`(\w+)="([^"]*)"`)
+	macRe  = regexp.MustCompile(`mac="[^"]{4}([^"]*)"`)
+)
+
+// record captures the Hawk attributes and request details of r, restoring
+// the body for verification.
+func record(r *http.Request) Request {
+	rec := Request{
+		Method:      r.Method,
+		URI:         r.RequestURI,
+		ContentType: r.Header.Get("Content-Type")}
+	var err error
+	if rec.Host, rec.Port, err = net.SplitHostPort(r.Host); err != nil {
+		rec.Host = r.Host
+	}
+	if r.Body != nil {
+		rec.Payload, _ = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(rec.Payload))
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Hawk ") {
+		return rec
+	}
+	for _, e := range attrRe.FindAllStringSubmatch(auth, -1) {
+		switch e[1] {
+		case "id":
+			rec.ID = e[2]
+		case "ts":
+			rec.Ts, _ = strconv.ParseInt(e[2], 10, 64)
+		case "nonce":
+			rec.Nonce = e[2]
+		case "hash":
+			rec.Hash = e[2]
+		case "ext":
+			rec.Ext = e[2]
+		case "mac":
+			rec.MAC = e[2]
+		}
+	}
+	return rec
+}
@@ -0,0 +1,187 @@
+package hawktest
+
+import (
+	"crypto"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	hawk "gitlab.com/tdely/go-hawk"
+)
+
+func startServer(t *testing.T) *Server {
+	s := NewServer(nil)
+	t.Cleanup(s.Close)
+	s.AddCredentials(hawk.Credentials{ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256})
+	return s
+}
+
+func do(t *testing.T, hc *hawk.Client, method string, url string, body string) *http.Response {
+	var req *http.Request
+	var err error
+	if body == "" {
+		req, err = hc.NewRequest(method, url, nil, "", "")
+	} else {
+		req, err = hc.NewRequest(method, url, strings.NewReader(body), "text/plain", "some-ext")
+	}
+	if err != nil {
+		t.Fatalf("NewRequest failed: %s", err.Error())
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %s", err.Error())
+	}
+	return resp
+}
+
+func TestServer(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		s := startServer(t)
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		resp := do(t, &hc, "POST", s.URL+"/hello?a=1", "Hello world!")
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("Server failed:\n  got:  %d\n  want: %d", got, want)
+		}
+		if !hc.ValidateResponse(*resp) {
+			t.Errorf("ValidateResponse failed: signed response not valid")
+		}
+		reqs := s.Requests()
+		if got, want := len(reqs), 1; got != want {
+			t.Fatalf("Requests failed:\n  got:  %d\n  want: %d", got, want)
+		}
+		r := reqs[0]
+		if r.Err != nil {
+			t.Errorf("Requests failed: %s", r.Err.Error())
+		}
+		if r.ID != "jdoe" || r.Ext != "some-ext" || r.Hash == "" || r.Nonce == "" || r.Ts == 0 {
+			t.Errorf("Requests failed: unexpected artifacts: %+v", r)
+		}
+		if got, want := r.URI, "/hello?a=1"; got != want {
+			t.Errorf("Requests failed:\n  got:  %s\n  want: %s", got, want)
+		}
+		if got, want := string(r.Payload), "Hello world!"; got != want {
+			t.Errorf("Requests failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("handler", func(t *testing.T) {
+		s := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":1}`))
+		}))
+		defer s.Close()
+		s.AddCredentials(hawk.Credentials{ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256})
+		s.SetResponseExt("response-specific")
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		resp := do(t, &hc, "GET", s.URL+"/things", "")
+		if got, want := resp.StatusCode, http.StatusCreated; got != want {
+			t.Fatalf("Server failed:\n  got:  %d\n  want: %d", got, want)
+		}
+		if !strings.Contains(resp.Header.Get("Server-Authorization"), `ext="response-specific"`) {
+			t.Errorf("Server failed: ext missing from %s", resp.Header.Get("Server-Authorization"))
+		}
+		if !hc.ValidateResponse(*resp) {
+			t.Errorf("ValidateResponse failed: signed response not valid")
+		}
+	})
+	t.Run("wrong-key", func(t *testing.T) {
+		s := startServer(t)
+		hc := hawk.NewClient("jdoe", []byte("wrong"), crypto.SHA256, 6)
+		resp := do(t, &hc, "GET", s.URL+"/hello", "")
+		if got, want := resp.StatusCode, http.StatusUnauthorized; got != want {
+			t.Errorf("Server failed:\n  got:  %d\n  want: %d", got, want)
+		}
+		if got, want := s.Requests()[0].Err, hawk.ErrInvalidMAC; got != want {
+			t.Errorf("Requests failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+	t.Run("algorithm", func(t *testing.T) {
+		s := startServer(t)
+		s.AddCredentials(hawk.Credentials{ID: "legacy", Key: []byte("Syp9393"), Algorithm: crypto.SHA1})
+		hc := hawk.NewClient("legacy", []byte("Syp9393"), crypto.SHA1, 6)
+		if resp := do(t, &hc, "GET", s.URL+"/hello", ""); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Server failed: SHA1 accepted by default")
+		}
+		s.SetAlgorithms(crypto.SHA1)
+		if resp := do(t, &hc, "GET", s.URL+"/hello", ""); resp.StatusCode != http.StatusOK {
+			t.Errorf("Server failed: SHA1 rejected after SetAlgorithms")
+		}
+	})
+	t.Run("tamper-mac", func(t *testing.T) {
+		s := startServer(t)
+		s.SetResponseMode(TamperMAC)
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		resp := do(t, &hc, "GET", s.URL+"/hello", "")
+		if hc.ValidateResponse(*resp) {
+			t.Errorf("ValidateResponse failed: tampered MAC accepted")
+		}
+	})
+	t.Run("tamper-hash", func(t *testing.T) {
+		s := startServer(t)
+		s.SetResponseMode(TamperHash)
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		resp := do(t, &hc, "GET", s.URL+"/hello", "")
+		if hc.ValidateResponse(*resp) {
+			t.Errorf("ValidateResponse failed: tampered hash accepted")
+		}
+	})
+	t.Run("no-signature", func(t *testing.T) {
+		s := startServer(t)
+		s.SetResponseMode(NoSignature)
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		resp := do(t, &hc, "GET", s.URL+"/hello", "")
+		if resp.Header.Get("Server-Authorization") != "" {
+			t.Errorf("Server failed: response signed")
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		if got, want := string(b), "OK."; got != want {
+			t.Errorf("Server failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("stale-timestamp", func(t *testing.T) {
+		s := startServer(t)
+		s.SetClockOffset(time.Hour)
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		resp := do(t, &hc, "GET", s.URL+"/hello", "")
+		if got, want := resp.StatusCode, http.StatusUnauthorized; got != want {
+			t.Fatalf("Server failed:\n  got:  %d\n  want: %d", got, want)
+		}
+		if got, want := s.Requests()[0].Err, hawk.ErrStaleTimestamp; got != want {
+			t.Errorf("Requests failed:\n  got:  %v\n  want: %v", got, want)
+		}
+		m := regexp.MustCompile(`ts="(\d+)", tsm="[^"]+", error="Stale timestamp"`).FindStringSubmatch(resp.Header.Get("WWW-Authenticate"))
+		if m == nil {
+			t.Fatalf("Server failed: no timestamp challenge in %q", resp.Header.Get("WWW-Authenticate"))
+		}
+		ts, _ := strconv.ParseInt(m[1], 10, 64)
+		if want := time.Now().Add(time.Hour).Unix(); ts < want-5 || ts > want {
+			t.Errorf("Server failed:\n  got challenge ts:  %d\n  want challenge ts: %d", ts, want)
+		}
+	})
+	t.Run("replay", func(t *testing.T) {
+		s := startServer(t)
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		req, _ := hc.NewRequest("GET", s.URL+"/hello", nil, "", "")
+		http.DefaultClient.Do(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %s", err.Error())
+		}
+		if got, want := resp.StatusCode, http.StatusUnauthorized; got != want {
+			t.Errorf("Server failed:\n  got:  %d\n  want: %d", got, want)
+		}
+	})
+	t.Run("reset", func(t *testing.T) {
+		s := startServer(t)
+		hc := hawk.NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+		do(t, &hc, "GET", s.URL+"/hello", "")
+		s.Reset()
+		if got, want := len(s.Requests()), 0; got != want {
+			t.Errorf("Reset failed:\n  got:  %d\n  want: %d", got, want)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package hawk
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Errors returned for credentials used outside their lifetime.
+var (
+	ErrCredentialsExpired     = errors.New("Credentials expired")
+	ErrCredentialsRevoked     = errors.New("Credentials revoked")
+	ErrCredentialsNotYetValid = errors.New("Credentials not yet valid")
+)
+
+// Valid returns nil if c may be used at t, or the reason it may not.
+func (c Credentials) Valid(t time.Time) error {
+	switch {
+	case c.Revoked:
+		return ErrCredentialsRevoked
+	case !c.NotBefore.IsZero() && t.Before(c.NotBefore):
+		return ErrCredentialsNotYetValid
+	case !c.NotAfter.IsZero() && !t.Before(c.NotAfter):
+		return ErrCredentialsExpired
+	}
+	return nil
+}
+
+// NewClientWithCredentials creates a new Hawk client that signs with creds
+// for as long as they are valid. Once they are not, the client asks
+// Client.Refresh for new credentials, or refuses to sign if it is nil.
+func NewClientWithCredentials(creds Credentials, nonceLength int) Client {
+	return Client{uid: creds.ID, key: creds.Key, hash: creds.Algorithm, signer: creds.Signer(), creds: creds, NonceLength: nonceLength}
+}
+
+// refresh replaces the credentials of c with valid ones from c.Refresh and
+// returns the id and Signer to sign with. Concurrent callers wait for the
+// first, and use the credentials it got.
+func (c *Client) refresh(ctx context.Context) (string, Signer, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	c.mu.Lock()
+	uid, signer, creds := c.uid, c.signer, c.creds
+	c.mu.Unlock()
+	if creds.Valid(time.Now()) == nil {
+		return uid, signer, nil
+	}
+	creds, err := c.Refresh(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := creds.Valid(time.Now()); err != nil {
+		return "", nil, err
+	}
+	c.mu.Lock()
+	signer = c.setCredentials(creds)
+	c.mu.Unlock()
+	return creds.ID, signer, nil
+}
+
+// setCredentials makes c sign with creds and returns their Signer. It must
+// be called with c.mu held.
+func (c *Client) setCredentials(creds Credentials) Signer {
+	c.uid, c.key, c.hash = creds.ID, creds.Key, creds.Algorithm
+	c.signer = creds.Signer()
+	c.creds = creds
+	return c.signer
+}
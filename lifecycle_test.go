@@ -0,0 +1,150 @@
+package hawk
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCredentialsValid(t *testing.T) {
+	now := time.Unix(1353832234, 0)
+	cases := []struct {
+		creds Credentials
+		want  error
+		name  string
+	}{
+		{Credentials{}, nil, "no lifetime"},
+		{Credentials{NotBefore: now, NotAfter: now.Add(time.Second)}, nil, "within"},
+		{Credentials{NotBefore: now.Add(time.Second)}, ErrCredentialsNotYetValid, "not yet valid"},
+		{Credentials{NotAfter: now}, ErrCredentialsExpired, "expired"},
+		{Credentials{NotAfter: now.Add(time.Hour), Revoked: true}, ErrCredentialsRevoked, "revoked"},
+	}
+	for _, c := range cases {
+		if got := c.creds.Valid(now); got != c.want {
+			t.Errorf("Valid %s failed:\n  got:  %v\n  want: %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestVerifierLifecycle(t *testing.T) {
+	now := time.Now()
+	key := []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")
+	creds := CredentialMap{
+		"valid":   {ID: "valid", Key: key, Algorithm: crypto.SHA256, NotAfter: now.Add(time.Hour)},
+		"expired": {ID: "expired", Key: key, Algorithm: crypto.SHA256, NotAfter: now.Add(-time.Hour)},
+		"future":  {ID: "future", Key: key, Algorithm: crypto.SHA256, NotBefore: now.Add(time.Hour)},
+		"revoked": {ID: "revoked", Key: key, Algorithm: crypto.SHA256, Revoked: true},
+	}
+	v := &Verifier{Credentials: creds}
+	cases := []struct {
+		id   string
+		key  []byte
+		want error
+	}{
+		{"valid", key, nil},
+		{"expired", key, ErrCredentialsExpired},
+		{"future", key, ErrCredentialsNotYetValid},
+		{"revoked", key, ErrCredentialsRevoked},
+		// The state of credentials is not revealed without the key.
+		{"revoked", []byte("guess"), ErrInvalidMAC},
+	}
+	for _, c := range cases {
+		hc := NewClient(c.id, c.key, crypto.SHA256, 6)
+		req, err := hc.NewRequest("GET", "http://example.com/resource", nil, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = v.VerifyHeader(req.Header.Get("Authorization"), "GET", "/resource", "example.com", "80")
+		if err != c.want {
+			t.Errorf("VerifyHeader %s failed:\n  got:  %v\n  want: %v", c.id, err, c.want)
+		}
+	}
+
+	msg := []byte("Hello")
+	m, err := NewMessage(creds["expired"], "example.com", "8080", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.VerifyMessage("example.com", "8080", msg, m); err != ErrCredentialsExpired {
+		t.Errorf("VerifyMessage failed:\n  got:  %v\n  want: %v", err, ErrCredentialsExpired)
+	}
+}
+
+func TestClientLifecycle(t *testing.T) {
+	key := []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")
+	expired := Credentials{ID: "old", Key: key, Algorithm: crypto.SHA256, NotAfter: time.Now().Add(-time.Second)}
+
+	c := NewClientWithCredentials(expired, 6)
+	if _, err := c.NewRequest("GET", "http://example.com/resource", nil, "", ""); err != ErrCredentialsExpired {
+		t.Errorf("NewRequest failed:\n  got:  %v\n  want: %v", err, ErrCredentialsExpired)
+	}
+
+	refreshed := 0
+	c.Refresh = func(ctx context.Context) (Credentials, error) {
+		refreshed++
+		return Credentials{ID: "new", Key: key, Algorithm: crypto.SHA256, NotAfter: time.Now().Add(time.Hour)}, nil
+	}
+	for i := 0; i < 2; i++ {
+		req, err := c.NewRequest("GET", "http://example.com/resource", nil, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		attrs, _ := parseHeader(req.Header.Get("Authorization"))
+		if got, want := attrs["id"], "new"; got != want {
+			t.Errorf("NewRequest failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	}
+	if refreshed != 1 {
+		t.Errorf("Refresh failed:\n  got:  %v calls\n  want: %v calls", refreshed, 1)
+	}
+
+	errRefresh := errors.New("refresh failed")
+	c = NewClientWithCredentials(expired, 6)
+	c.Refresh = func(ctx context.Context) (Credentials, error) {
+		return Credentials{}, errRefresh
+	}
+	if _, err := c.Bewit("http://example.com/resource", time.Minute, ""); err != errRefresh {
+		t.Errorf("Bewit failed:\n  got:  %v\n  want: %v", err, errRefresh)
+	}
+	c.Refresh = func(ctx context.Context) (Credentials, error) {
+		return Credentials{ID: "revoked", Key: key, Algorithm: crypto.SHA256, Revoked: true}, nil
+	}
+	if _, err := c.Bewit("http://example.com/resource", time.Minute, ""); err != ErrCredentialsRevoked {
+		t.Errorf("Bewit revoked failed:\n  got:  %v\n  want: %v", err, ErrCredentialsRevoked)
+	}
+}
+
+func TestClientRefreshConcurrent(t *testing.T) {
+	key := []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")
+	c := NewClientWithCredentials(Credentials{ID: "old", Key: key, Algorithm: crypto.SHA256, NotAfter: time.Now().Add(-time.Second)}, 6)
+	var refreshed int32
+	c.Refresh = func(ctx context.Context) (Credentials, error) {
+		atomic.AddInt32(&refreshed, 1)
+		return Credentials{ID: "new", Key: key, Algorithm: crypto.SHA256, NotAfter: time.Now().Add(time.Hour)}, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "http://example.com/resource", nil)
+			if _, err := c.Sign(req, ""); err != nil {
+				t.Error(err)
+				return
+			}
+			attrs, _ := parseHeader(req.Header.Get("Authorization"))
+			if got, want := attrs["id"], "new"; got != want {
+				t.Errorf("Sign failed:\n  got:  %v\n  want: %v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+	if refreshed != 1 {
+		t.Errorf("Refresh failed:\n  got:  %v calls\n  want: %v calls", refreshed, 1)
+	}
+}
@@ -0,0 +1,104 @@
+package hawk
+
+import (
+	"context"
+	"crypto/hmac"
+	"time"
+)
+
+// Message is the Hawk authorization of a single message, sent alongside
+// the message instead of in an HTTP header.
+type Message struct {
+	ID    string `json:"id"`
+	Ts    int64  `json:"ts"`
+	Nonce string `json:"nonce"`
+	Hash  string `json:"hash"`
+	MAC   string `json:"mac"`
+}
+
+// NewMessage authorizes msg for sending to host and port with creds.
+func NewMessage(creds Credentials, host string, port string, msg []byte) (Message, error) {
+	return newMessage(creds.ID, creds.Signer(), host, port, "", msg, 6)
+}
+
+// Message authorizes msg for sending to host and port.
+func (c *Client) Message(host string, port string, msg []byte) (Message, error) {
+	return c.MessageContext(context.Background(), host, port, msg)
+}
+
+// MessageContext is Message with a context that is passed on to the Refresh
+// function or CredentialProvider of the client.
+func (c *Client) MessageContext(ctx context.Context, host string, port string, msg []byte) (Message, error) {
+	uid, signer, err := c.signerFor(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	return newMessage(uid, signer, host, port, "", msg, c.NonceLength)
+}
+
+// newMessage authorizes msg with ext, which is part of the MAC but is not
+// sent.
+func newMessage(uid string, s Signer, host string, port string, ext string, msg []byte, nonceLength int) (Message, error) {
+	if AlgorithmName(s.Algorithm()) == "" {
+		return Message{}, ErrUnknownAlgorithm
+	}
+	m := Message{ID: uid, Ts: time.Now().Unix(), Nonce: NewNonce(nonceLength)}
+	if m.Nonce == "" {
+		m.Nonce = NewNonce(6)
+	}
+	m.Hash = hashPayload(s.Algorithm(), "", msg)
+	mac, err := signMAC(s, "message", m.Ts, m.Nonce, "", "", host, port, m.Hash, ext)
+	if err != nil {
+		return Message{}, err
+	}
+	m.MAC = mac
+	return m, nil
+}
+
+// VerifyMessage authenticates msg received on host and port with its
+// authorization m. As with requests, the timestamp must be within the
+// allowed skew and the nonce is checked against Nonces if set.
+func (v *Verifier) VerifyMessage(host string, port string, msg []byte, m Message) (Credentials, error) {
+	return v.VerifyMessageContext(context.Background(), host, port, msg, m)
+}
+
+// VerifyMessageContext is VerifyMessage with a context that is passed on to
+// the credential and nonce stores.
+func (v *Verifier) VerifyMessageContext(ctx context.Context, host string, port string, msg []byte, m Message) (Credentials, error) {
+	return v.verifyMessage(ctx, host, port, "", msg, m)
+}
+
+// verifyMessage is VerifyMessageContext for messages authorized with ext,
+// see newMessage.
+func (v *Verifier) verifyMessage(ctx context.Context, host string, port string, ext string, msg []byte, m Message) (Credentials, error) {
+	if v.Credentials == nil {
+		return Credentials{}, ErrNoCredentialStore
+	}
+	if m.ID == "" || m.Nonce == "" || m.Hash == "" || m.MAC == "" {
+		return Credentials{}, ErrMalformedHeader
+	}
+	creds, err := v.lookup(ctx, m.ID, m.MAC, func(c Credentials) string {
+		return hashMAC(c.Algorithm, c.Key, "message", m.Ts, m.Nonce, "", "", host, port, m.Hash, ext)
+	})
+	if err != nil {
+		return Credentials{}, err
+	}
+	if err := creds.Valid(v.now()); err != nil {
+		return Credentials{}, err
+	}
+	calcHash := hashPayload(creds.Algorithm, "", msg)
+	if !hmac.Equal([]byte(calcHash), []byte(m.Hash)) {
+		return Credentials{}, ErrInvalidPayloadHash
+	}
+	if !v.fresh(m.Ts) {
+		return creds, ErrStaleTimestamp
+	}
+	if v.Nonces != nil {
+		if err := v.Nonces.Check(ctx, m.ID, m.Nonce, m.Ts); err == ErrStaleTimestamp {
+			return creds, err
+		} else if err != nil {
+			return Credentials{}, err
+		}
+	}
+	return creds, nil
+}
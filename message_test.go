@@ -0,0 +1,55 @@
+package hawk
+
+import (
+	"crypto"
+	"testing"
+)
+
+func TestMessage(t *testing.T) {
+	creds := Credentials{ID: "123456", Key: []byte("2983d45yun89q"), Algorithm: crypto.SHA256}
+	v := &Verifier{Credentials: CredentialMap{"123456": creds}}
+	hc := NewClient("123456", []byte("2983d45yun89q"), crypto.SHA256, 6)
+	t.Run("ok", func(t *testing.T) {
+		m, err := hc.Message("example.com", "8080", []byte("I am the boodyman"))
+		if err != nil {
+			t.Fatalf("Message failed: %s", err.Error())
+		}
+		if _, err := v.VerifyMessage("example.com", "8080", []byte("I am the boodyman"), m); err != nil {
+			t.Errorf("VerifyMessage failed: %s", err.Error())
+		}
+	})
+	t.Run("altered-message", func(t *testing.T) {
+		m, _ := NewMessage(creds, "example.com", "8080", []byte("I am the boodyman"))
+		if _, err := v.VerifyMessage("example.com", "8080", []byte("I am the bogeyman"), m); err != ErrInvalidPayloadHash {
+			t.Errorf("VerifyMessage failed:\n  got:  %v\n  want: %v", err, ErrInvalidPayloadHash)
+		}
+	})
+	t.Run("other-host", func(t *testing.T) {
+		m, _ := NewMessage(creds, "example.com", "8080", []byte("I am the boodyman"))
+		if _, err := v.VerifyMessage("example.net", "8080", []byte("I am the boodyman"), m); err != ErrInvalidMAC {
+			t.Errorf("VerifyMessage failed:\n  got:  %v\n  want: %v", err, ErrInvalidMAC)
+		}
+	})
+	t.Run("missing-mac", func(t *testing.T) {
+		m, _ := NewMessage(creds, "example.com", "8080", []byte("I am the boodyman"))
+		m.MAC = ""
+		if _, err := v.VerifyMessage("example.com", "8080", []byte("I am the boodyman"), m); err != ErrMalformedHeader {
+			t.Errorf("VerifyMessage failed:\n  got:  %v\n  want: %v", err, ErrMalformedHeader)
+		}
+	})
+	t.Run("stale", func(t *testing.T) {
+		m, _ := NewMessage(creds, "example.com", "8080", []byte("old"))
+		m.Ts -= 3600
+		m.MAC = hashMAC(creds.Algorithm, creds.Key, "message", m.Ts, m.Nonce, "", "", "example.com", "8080", m.Hash, "")
+		if _, err := v.VerifyMessage("example.com", "8080", []byte("old"), m); err != ErrStaleTimestamp {
+			t.Errorf("VerifyMessage failed:\n  got:  %v\n  want: %v", err, ErrStaleTimestamp)
+		}
+	})
+	t.Run("unknown-algorithm", func(t *testing.T) {
+		c := creds
+		c.Algorithm = crypto.MD5
+		if _, err := NewMessage(c, "example.com", "8080", []byte("x")); err != ErrUnknownAlgorithm {
+			t.Errorf("NewMessage failed:\n  got:  %v\n  want: %v", err, ErrUnknownAlgorithm)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package hawk
+
+import (
+	"crypto"
+	"crypto/hkdf"
+	"encoding/hex"
+	"errors"
+)
+
+// mozillaInfo is prefixed to the token type in the HKDF info of Firefox
+// Accounts tokens.
+const mozillaInfo = "identity.mozilla.com/picl/v1/"
+
+// Firefox Accounts token types.
+const (
+	SessionToken        = "sessionToken"
+	KeyFetchToken       = "keyFetchToken"
+	AccountResetToken   = "accountResetToken"
+	PasswordChangeToken = "passwordChangeToken"
+)
+
+// ErrInvalidToken is returned for tokens that are not 32 hex encoded bytes.
+var ErrInvalidToken = errors.New("Invalid token")
+
+// TokenCredentials derives Hawk credentials from a hex encoded Firefox
+// Accounts token of the given type, e.g. SessionToken, the way Firefox
+// Accounts and Sync servers do: the first 32 bytes of
+// HKDF-SHA256(token, "", "identity.mozilla.com/picl/v1/"+typ) are the id,
+// hex encoded, and the next 32 bytes the key. The algorithm is SHA256.
+func TokenCredentials(token string, typ string) (Credentials, error) {
+	b, err := hex.DecodeString(token)
+	if err != nil || len(b) != 32 {
+		return Credentials{}, ErrInvalidToken
+	}
+	out, err := hkdf.Key(crypto.SHA256.New, b, nil, mozillaInfo+typ, 64)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{ID: hex.EncodeToString(out[:32]), Key: out[32:], Algorithm: crypto.SHA256}, nil
+}
+
+// NewSessionTokenClient creates a new Hawk client with the credentials of
+// a hex encoded Firefox Accounts session token.
+func NewSessionTokenClient(token string, nonceLength int) (Client, error) {
+	creds, err := TokenCredentials(token, SessionToken)
+	if err != nil {
+		return Client{}, err
+	}
+	return NewClient(creds.ID, creds.Key, creds.Algorithm, nonceLength), nil
+}
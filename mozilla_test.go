@@ -0,0 +1,65 @@
+package hawk
+
+import (
+	"crypto"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Token vectors from the Firefox Accounts onepw protocol.
+const testToken = "a0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebf"
+
+func TestTokenCredentials(t *testing.T) {
+	cases := []struct {
+		typ string
+		id  string
+		key string
+	}{
+		{SessionToken,
+			"c0a29dcf46174973da1378696e4c82ae10f723cf4f4d9f75e39f4ae3851595ab",
+			"9d8f22998ee7f5798b887042466b72d53e56ab0c094388bf65831f702d2febc0"},
+		{KeyFetchToken,
+			"70db599cec9c040b10c790418f93fe77711fdea352a59e9b02d2336136d39f68",
+			"f936647aab7765642f3ee1c704751e501f50f8188ec55c31df4dbfc28f16816f"},
+	}
+	for _, c := range cases {
+		creds, err := TokenCredentials(testToken, c.typ)
+		if err != nil {
+			t.Fatalf("TokenCredentials failed for %s: %s", c.typ, err.Error())
+		}
+		if creds.ID != c.id || hex.EncodeToString(creds.Key) != c.key || creds.Algorithm != crypto.SHA256 {
+			t.Errorf("TokenCredentials failed for %s:\n  got:  %s %x\n  want: %s %s", c.typ, creds.ID, creds.Key, c.id, c.key)
+		}
+	}
+
+	for _, bad := range []string{"", "a0a1", "zz" + testToken[2:], testToken + "c0"} {
+		if _, err := TokenCredentials(bad, SessionToken); err != ErrInvalidToken {
+			t.Errorf("TokenCredentials failed for %q:\n  got:  %v\n  want: %v", bad, err, ErrInvalidToken)
+		}
+	}
+}
+
+func TestSessionTokenClient(t *testing.T) {
+	c, err := NewSessionTokenClient(strings.ToUpper(testToken), 6)
+	if err != nil {
+		t.Fatalf("NewSessionTokenClient failed: %s", err.Error())
+	}
+	hd := Details{
+		Algorithm: c.hash,
+		Host:      "api.accounts.firefox.com",
+		Port:      "443",
+		URI:       "/v1/account/devices",
+		Method:    "GET",
+		Timestamp: 1353832234,
+		Nonce:     "j4h3g2"}
+	h, _ := hd.Create()
+	h.Finalize(c.key)
+	want := `Hawk id="c0a29dcf46174973da1378696e4c82ae10f723cf4f4d9f75e39f4ae3851595ab", ts="1353832234", nonce="j4h3g2", mac="CJMsDR0WgwkX+ToRNDCXXUhcVFU+5sDg4AWxdIkpXLY="`
+	if got := h.GetAuthorization(c.uid); got != want {
+		t.Errorf("NewSessionTokenClient failed:\n  got:  %s\n  want: %s", got, want)
+	}
+	if _, err := NewSessionTokenClient("not-a-token", 6); err != ErrInvalidToken {
+		t.Errorf("NewSessionTokenClient failed:\n  got:  %v\n  want: %v", err, ErrInvalidToken)
+	}
+}
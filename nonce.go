@@ -0,0 +1,59 @@
+package hawk
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultNonceWindow is how long a MemoryNonceStore without a Window of
+// its own remembers nonces.
+const DefaultNonceWindow = 2 * time.Minute
+
+// MemoryNonceStore is a NonceStore that keeps nonces in memory. Nonces are
+// remembered for Window; requests with timestamps further in the past than
+// that cannot be checked and are rejected with ErrStaleTimestamp.
+type MemoryNonceStore struct {
+	Window time.Duration
+
+	mu     sync.Mutex
+	nonces map[string]int64
+	pruned int64
+}
+
+// Check records nonce for id and returns ErrReplayedNonce if it was already
+// used within the window.
+func (s *MemoryNonceStore) Check(ctx context.Context, id string, nonce string, ts int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	window := s.Window
+	if window == 0 {
+		window = DefaultNonceWindow
+	}
+	now := time.Now().Unix()
+	oldest := now - int64(window.Seconds())
+	if ts < oldest {
+		return ErrStaleTimestamp
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nonces == nil {
+		s.nonces = make(map[string]int64)
+	}
+	if now-s.pruned >= int64(window.Seconds()) {
+		for k, t := range s.nonces {
+			if t < oldest {
+				delete(s.nonces, k)
+			}
+		}
+		s.pruned = now
+	}
+	key := id + "\x00" + nonce
+	if _, ok := s.nonces[key]; ok {
+		return ErrReplayedNonce
+	}
+	s.nonces[key] = ts
+	return nil
+}
@@ -0,0 +1,52 @@
+package hawk
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryNonceStore(t *testing.T) {
+	ctx := context.Background()
+	t.Run("replay", func(t *testing.T) {
+		s := &MemoryNonceStore{}
+		ts := time.Now().Unix()
+		if err := s.Check(ctx, "jdoe", "abc123", ts); err != nil {
+			t.Fatalf("Check failed: %s", err.Error())
+		}
+		if got, want := s.Check(ctx, "jdoe", "abc123", ts), ErrReplayedNonce; got != want {
+			t.Errorf("Check failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+	t.Run("per-id", func(t *testing.T) {
+		s := &MemoryNonceStore{}
+		ts := time.Now().Unix()
+		s.Check(ctx, "jdoe", "abc123", ts)
+		if err := s.Check(ctx, "jroe", "abc123", ts); err != nil {
+			t.Errorf("Check failed: %s", err.Error())
+		}
+	})
+	t.Run("stale", func(t *testing.T) {
+		s := &MemoryNonceStore{Window: time.Minute}
+		ts := time.Now().Add(-2 * time.Minute).Unix()
+		if got, want := s.Check(ctx, "jdoe", "abc123", ts), ErrStaleTimestamp; got != want {
+			t.Errorf("Check failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+	t.Run("prune", func(t *testing.T) {
+		s := &MemoryNonceStore{Window: time.Minute}
+		s.nonces = map[string]int64{"jdoe\x00old": time.Now().Add(-time.Hour).Unix()}
+		s.Check(ctx, "jdoe", "new", time.Now().Unix())
+		if got, want := len(s.nonces), 1; got != want {
+			t.Errorf("Check failed:\n  got nonces:  %d\n  want nonces: %d", got, want)
+		}
+	})
+	t.Run("canceled", func(t *testing.T) {
+		s := &MemoryNonceStore{}
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if got, want := s.Check(cctx, "jdoe", "abc123", time.Now().Unix()), context.Canceled; got != want {
+			t.Errorf("Check failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	})
+}
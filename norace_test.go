@@ -0,0 +1,5 @@
+//go:build !race
+
+package hawk
+
+const raceEnabled = false
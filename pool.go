@@ -0,0 +1,170 @@
+package hawk
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	b64 "encoding/base64"
+	"hash"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxMACPools bounds the number of keys HMAC states are pooled for. Once it
+// is reached, a pool that has not been used lately is evicted for every
+// new key.
+const maxMACPools = 1024
+
+// hashState is a pooled hash state with room for its sum, which would
+// escape to the heap if it were passed to Sum from the stack.
+type hashState struct {
+	hash.Hash
+	sum [sha512.Size]byte
+	buf []byte
+}
+
+// macPools holds pools of keyed HMAC states per algorithm and key, so that
+// the key schedule is not recomputed for every MAC. Pools are found by a
+// digest of the key, and evicted by the CLOCK algorithm: a pool is marked
+// as used on every lookup, and the hand clears marks until it finds a pool
+// that is not, so that keys in use stay while those rotated out or
+// revoked are released.
+var macPools = struct {
+	sync.RWMutex
+	m    map[macKey]*macEntry
+	ring []*macEntry
+	hand int
+}{m: map[macKey]*macEntry{}}
+
+// macKey identifies the pool of an algorithm and key.
+type macKey struct {
+	hash   crypto.Hash
+	digest [sha256.Size]byte
+}
+
+type macEntry struct {
+	key  macKey
+	pool *sync.Pool
+	used uint32
+}
+
+// hashPools holds pools of plain hash states per algorithm.
+var hashPools sync.Map
+
+// bufPool holds buffers for building normalized strings.
+var bufPool = sync.Pool{New: func() interface{} {
+	b := make([]byte, 0, 256)
+	return &b
+}}
+
+func macPool(h crypto.Hash, k []byte) *sync.Pool {
+	mk := macKey{hash: h, digest: sha256.Sum256(k)}
+	macPools.RLock()
+	e := macPools.m[mk]
+	macPools.RUnlock()
+	if e != nil {
+		if atomic.LoadUint32(&e.used) == 0 {
+			atomic.StoreUint32(&e.used, 1)
+		}
+		return e.pool
+	}
+	macPools.Lock()
+	defer macPools.Unlock()
+	if e = macPools.m[mk]; e != nil {
+		return e.pool
+	}
+	key := append([]byte(nil), k...)
+	e = &macEntry{key: mk, pool: &sync.Pool{New: func() interface{} { return &hashState{Hash: hmac.New(h.New, key)} }}}
+	if len(macPools.ring) < maxMACPools {
+		macPools.ring = append(macPools.ring, e)
+	} else {
+		for {
+			old := macPools.ring[macPools.hand]
+			if atomic.LoadUint32(&old.used) == 0 {
+				delete(macPools.m, old.key)
+				macPools.ring[macPools.hand] = e
+				break
+			}
+			atomic.StoreUint32(&old.used, 0)
+			macPools.hand = (macPools.hand + 1) % maxMACPools
+		}
+		macPools.hand = (macPools.hand + 1) % maxMACPools
+	}
+	macPools.m[mk] = e
+	return e.pool
+}
+
+// getMAC returns a reset HMAC state of h keyed with k, to be returned with
+// putMAC.
+func getMAC(h crypto.Hash, k []byte) (*hashState, *sync.Pool) {
+	p := macPool(h, k)
+	return p.Get().(*hashState), p
+}
+
+func putMAC(s *hashState, p *sync.Pool) {
+	s.Reset()
+	p.Put(s)
+}
+
+// macString returns the base64 encoded HMAC of data with h and k.
+func macString(h crypto.Hash, k []byte, data []byte) string {
+	s, p := getMAC(h, k)
+	s.Write(data)
+	mac := encodeSum(s.Sum(s.sum[:0]))
+	putMAC(s, p)
+	return mac
+}
+
+// getHash returns a reset hash state of h, to be returned with putHash.
+func getHash(h crypto.Hash) *hashState {
+	if p, ok := hashPools.Load(h); ok {
+		return p.(*sync.Pool).Get().(*hashState)
+	}
+	p, _ := hashPools.LoadOrStore(h, &sync.Pool{New: func() interface{} { return &hashState{Hash: h.New()} }})
+	return p.(*sync.Pool).Get().(*hashState)
+}
+
+func putHash(h crypto.Hash, s *hashState) {
+	s.Reset()
+	if p, ok := hashPools.Load(h); ok {
+		p.(*sync.Pool).Put(s)
+	}
+}
+
+// encodeSum returns the base64 encoding of sum with a single allocation.
+func encodeSum(sum []byte) string {
+	var buf [b64MaxLen]byte
+	n := b64.StdEncoding.EncodedLen(len(sum))
+	b64.StdEncoding.Encode(buf[:n], sum)
+	return string(buf[:n])
+}
+
+// b64MaxLen is the padded base64 length of the largest digest.
+const b64MaxLen = (sha512.Size + 2) / 3 * 4
+
+// appendNormalized appends the normalized string of a MAC to b, see
+// normalizedString.
+func appendNormalized(b []byte, typ string, ts int64, n string, mtd string, uri string, hst string, p string, hsh string, ext string) []byte {
+	b = append(b, "hawk.1."...)
+	b = append(b, typ...)
+	b = append(b, '\n')
+	b = strconv.AppendInt(b, ts, 10)
+	b = append(b, '\n')
+	b = append(b, n...)
+	b = append(b, '\n')
+	b = append(b, strings.ToUpper(mtd)...)
+	b = append(b, '\n')
+	b = append(b, uri...)
+	b = append(b, '\n')
+	b = append(b, strings.ToLower(hst)...)
+	b = append(b, '\n')
+	b = append(b, p...)
+	b = append(b, '\n')
+	b = append(b, hsh...)
+	b = append(b, '\n')
+	b = append(b, extEscaper.Replace(ext)...)
+	return append(b, '\n')
+}
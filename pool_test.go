@@ -0,0 +1,178 @@
+package hawk
+
+import (
+	"crypto"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+var benchKey = []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")
+
+func TestAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items with the race detector")
+	}
+	content := []byte("Thank you for flying Hawk")
+	hd := Details{
+		Algorithm: crypto.SHA256,
+		Host:      "example.com",
+		Port:      "8000",
+		URI:       "/resource/1?b=1&a=2",
+		Method:    "GET",
+		Timestamp: 1353832234,
+		Nonce:     "j4h3g2",
+		Ext:       "some-app-ext-data"}
+	h, _ := hd.Create()
+	h.Finalize(benchKey)
+	cases := []struct {
+		name string
+		max  float64
+		f    func()
+	}{
+		{"hashMAC", 1, func() {
+			hashMAC(crypto.SHA256, benchKey, "header", 1353832234, "j4h3g2", "GET", "/resource/1?b=1&a=2", "example.com", "8000", "", "some-app-ext-data")
+		}},
+		{"hashPayload", 1, func() { hashPayload(crypto.SHA256, "text/plain", content) }},
+		{"GetAuthorization", 1, func() { h.GetAuthorization("dh37fgj492je") }},
+		{"parseHeader", 2, func() {
+			parseHeader(`Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", mac="6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="`)
+		}},
+	}
+	for _, c := range cases {
+		if got := testing.AllocsPerRun(100, c.f); got > c.max {
+			t.Errorf("%s failed: %v allocs, want at most %v", c.name, got, c.max)
+		}
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	re := regexp.MustCompile(hawkPattern)
+	headers := []string{
+		`Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", ext="some-app-ext-data", mac="6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="`,
+		`hawk id="a",ts="1",   mac="x"`,
+		`Hawk id="", ext="a=\"b", mac="x"`,
+		`Hawk id="a" junk= "b" x=="c" y_1="d" unterminated="e`,
+		`Hawk =""id="a"`,
+		`Hawk `,
+	}
+	for _, hdr := range headers {
+		want := map[string]string{}
+		for _, e := range re.FindAllStringSubmatch(hdr[5:], -1) {
+			want[e[1]] = e[2]
+		}
+		got, err := parseHeader(hdr)
+		if err != nil {
+			t.Fatalf("parseHeader failed for %s: %s", hdr, err.Error())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parseHeader failed for %s:\n  got:  %v\n  want: %v", hdr, got, want)
+		}
+	}
+	if _, err := parseHeader(`Basic dXNlcjpwYXNz`); err != ErrNoAuthorization {
+		t.Errorf("parseHeader failed:\n  got:  %v\n  want: %v", err, ErrNoAuthorization)
+	}
+}
+
+func TestMACPools(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < maxMACPools/4; j++ {
+				key := []byte(fmt.Sprintf("key-%d-%d", i, j))
+				got := hashMAC(crypto.SHA256, key, "header", 1353832234, "j4h3g2", "GET", "/", "example.com", "80", "", "")
+				want := Credentials{Key: key, Algorithm: crypto.SHA256}.Signer()
+				mac, _ := want.Sign([]byte(normalizedString("header", 1353832234, "j4h3g2", "GET", "/", "example.com", "80", "", "")))
+				if got != encodeSum(mac) {
+					t.Errorf("hashMAC failed for %s: MAC of other key", key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	macPools.RLock()
+	defer macPools.RUnlock()
+	if n := len(macPools.m); n > maxMACPools {
+		t.Errorf("macPool failed: %d pools, want at most %d", n, maxMACPools)
+	}
+}
+
+func TestMACPoolsEviction(t *testing.T) {
+	hot := []byte("hot")
+	p := macPool(crypto.SHA256, hot)
+	// More keys than there is room for, as with a large fleet of derived
+	// credentials, with one key in use all along.
+	for i := 0; i < 4*maxMACPools; i++ {
+		macPool(crypto.SHA256, []byte(fmt.Sprint("key-", i)))
+		if got := macPool(crypto.SHA256, hot); got != p {
+			t.Fatalf("macPool failed: pool of key in use evicted after %d keys", i)
+		}
+	}
+	macPools.RLock()
+	n := len(macPools.m)
+	macPools.RUnlock()
+	if n != maxMACPools {
+		t.Errorf("macPool failed:\n  got:  %d pools\n  want: %d pools", n, maxMACPools)
+	}
+	if got := macPool(crypto.SHA384, hot); got == p {
+		t.Errorf("macPool failed: pool shared between algorithms")
+	}
+}
+
+func BenchmarkHashMAC(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		hashMAC(crypto.SHA256, benchKey, "header", 1353832234, "j4h3g2", "GET", "/resource/1?b=1&a=2", "example.com", "8000", "", "some-app-ext-data")
+	}
+}
+
+func BenchmarkHashPayload(b *testing.B) {
+	content := []byte("Thank you for flying Hawk")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		hashPayload(crypto.SHA256, "text/plain", content)
+	}
+}
+
+func BenchmarkFinalize(b *testing.B) {
+	hd := Details{
+		Algorithm: crypto.SHA256,
+		Host:      "example.com",
+		Port:      "8000",
+		URI:       "/resource/1?b=1&a=2",
+		Method:    "GET",
+		Timestamp: 1353832234,
+		Nonce:     "j4h3g2",
+		Ext:       "some-app-ext-data"}
+	h, _ := hd.Create()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.reqMAC = ""
+		h.Finalize(benchKey)
+		h.GetAuthorization("dh37fgj492je")
+	}
+}
+
+func BenchmarkSign(b *testing.B) {
+	c := NewClient("dh37fgj492je", benchKey, crypto.SHA256, 6)
+	req, _ := http.NewRequest("GET", "https://example.com/resource/1?b=1&a=2", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.Sign(req, "some-app-ext-data")
+	}
+}
+
+func BenchmarkVerifyHeader(b *testing.B) {
+	v := &Verifier{Credentials: CredentialMap{"dh37fgj492je": {ID: "dh37fgj492je", Key: benchKey, Algorithm: crypto.SHA256}}}
+	c := NewClient("dh37fgj492je", benchKey, crypto.SHA256, 6)
+	auth, _ := c.Authorization("GET", "example.com", "443", "/resource/1?b=1&a=2", "")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v.VerifyHeader(auth, "GET", "/resource/1?b=1&a=2", "example.com", "443")
+	}
+}
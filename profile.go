@@ -0,0 +1,204 @@
+package hawk
+
+import (
+	"bufio"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultProfile is the profile used when no profile is named.
+const DefaultProfile = "default"
+
+// defaultNonceLength is the nonce length of clients created from profiles.
+const defaultNonceLength = 6
+
+// ErrNoProfile is returned when a named profile does not exist.
+var ErrNoProfile = errors.New("No such profile")
+
+// Profile is a named set of client credentials and settings.
+type Profile struct {
+	Name      string
+	ID        string
+	Key       []byte
+	Algorithm crypto.Hash
+	BaseURL   string
+	Ext       string
+	// CredentialProcess is a command that supplies id, key and algorithm
+	// instead, see ProcessProvider.
+	CredentialProcess string
+}
+
+// Client creates a new Hawk client from the profile.
+func (p Profile) Client() Client {
+	if p.CredentialProcess != "" {
+		return Client{provider: &ProcessProvider{Command: p.CredentialProcess}, NonceLength: defaultNonceLength, BaseURL: p.BaseURL, Ext: p.Ext}
+	}
+	return Client{uid: p.ID, key: p.Key, hash: p.Algorithm, signer: keySigner{key: p.Key, hash: p.Algorithm}, NonceLength: defaultNonceLength, BaseURL: p.BaseURL, Ext: p.Ext}
+}
+
+// ReadProfiles reads profiles from an INI style credentials file:
+//
+//     [default]
+//     id = dh37fgj492je
+//     key = werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn
+//     algorithm = sha256
+//
+//     [staging]
+//     id = 7gdh3j9aaee3
+//     key = 9w4rxnpa98w4rxnpaw3489ruxnpawerxhqb98rpaxn3
+//     base_url = https://staging.example.com
+//     ext = some-app-ext-data
+//
+//     [vault]
+//     credential_process = /usr/local/bin/hawk-credentials --role api
+//
+// Lines starting with # or ; are comments. The algorithm is sha256 unless
+// given.
+func ReadProfiles(r io.Reader) (map[string]Profile, error) {
+	profiles := map[string]Profile{}
+	var p *Profile
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			if p != nil {
+				profiles[p.Name] = *p
+			}
+			p = &Profile{Name: strings.TrimSpace(line[1 : len(line)-1]), Algorithm: crypto.SHA256}
+			continue
+		}
+		i := strings.Index(line, "=")
+		if p == nil || i == -1 {
+			return nil, fmt.Errorf("Malformed credentials line %d", n)
+		}
+		k, v := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		switch k {
+		case "id":
+			p.ID = v
+		case "key":
+			p.Key = []byte(v)
+		case "algorithm":
+			alg, err := LookupAlgorithm(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s for %s: %q", err, p.Name, v)
+			}
+			p.Algorithm = alg
+		case "base_url":
+			p.BaseURL = v
+		case "ext":
+			p.Ext = v
+		case "credential_process":
+			p.CredentialProcess = v
+		default:
+			return nil, fmt.Errorf("Unknown credentials key on line %d: %s", n, k)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if p != nil {
+		profiles[p.Name] = *p
+	}
+	return profiles, nil
+}
+
+// CredentialsFile returns the path of the credentials file:
+// $HAWK_CREDENTIALS_FILE if set, otherwise hawk/credentials in
+// $XDG_CONFIG_HOME or ~/.config.
+func CredentialsFile() string {
+	if path := os.Getenv("HAWK_CREDENTIALS_FILE"); path != "" {
+		return path
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "hawk", "credentials")
+}
+
+// LoadProfile loads a profile in order of precedence:
+//
+//  1. HAWK_ID, HAWK_KEY and HAWK_ALGORITHM from the environment
+//  2. The profile from the credentials file, see CredentialsFile
+//  3. The algorithm defaults to sha256
+//
+// If name is empty the profile is taken from HAWK_PROFILE, or
+// DefaultProfile. A profile named explicitly is loaded from the file only,
+// without the environment overriding it. A missing credentials file is
+// only an error if the environment does not provide both id and key.
+func LoadProfile(name string) (Profile, error) {
+	env := name == ""
+	if env {
+		if name = os.Getenv("HAWK_PROFILE"); name == "" {
+			name = DefaultProfile
+		}
+	}
+
+	p, err := loadFileProfile(name)
+	if err != nil && !(env && (os.IsNotExist(err) || err == ErrNoProfile)) {
+		return Profile{}, err
+	}
+	if env {
+		p.Name = name
+		if id := os.Getenv("HAWK_ID"); id != "" {
+			p.ID = id
+		}
+		if key := os.Getenv("HAWK_KEY"); key != "" {
+			p.Key = []byte(key)
+		}
+		if os.Getenv("HAWK_ID") != "" && os.Getenv("HAWK_KEY") != "" {
+			p.CredentialProcess = ""
+		}
+		if v := os.Getenv("HAWK_ALGORITHM"); v != "" {
+			if p.Algorithm, err = LookupAlgorithm(v); err != nil {
+				return Profile{}, fmt.Errorf("%s for HAWK_ALGORITHM: %q", err, v)
+			}
+		}
+		if p.Algorithm == 0 {
+			p.Algorithm = crypto.SHA256
+		}
+	}
+	if p.CredentialProcess == "" && (p.ID == "" || len(p.Key) == 0) {
+		return Profile{}, fmt.Errorf("No id and key for profile %s", name)
+	}
+	return p, nil
+}
+
+func loadFileProfile(name string) (Profile, error) {
+	f, err := os.Open(CredentialsFile())
+	if err != nil {
+		return Profile{}, err
+	}
+	defer f.Close()
+	profiles, err := ReadProfiles(f)
+	if err != nil {
+		return Profile{}, err
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return Profile{}, ErrNoProfile
+	}
+	return p, nil
+}
+
+// LoadClient creates a new Hawk client from the profile loaded by
+// LoadProfile.
+func LoadClient(name string) (Client, error) {
+	p, err := LoadProfile(name)
+	if err != nil {
+		return Client{}, err
+	}
+	return p.Client(), nil
+}
@@ -0,0 +1,157 @@
+package hawk
+
+import (
+	"crypto"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testProfiles = `# Hawk credentials
+[default]
+id = dh37fgj492je
+key = werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn
+
+[staging]
+id = 7gdh3j9aaee3
+key = 9w4rxnpa98w4rxnpaw3489ruxnpawerxhqb98rpaxn3
+algorithm = sha512
+base_url = https://staging.example.com/
+ext = some-app-ext-data
+`
+
+func writeProfiles(t *testing.T, content string) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HAWK_CREDENTIALS_FILE", path)
+	t.Setenv("HAWK_PROFILE", "")
+	t.Setenv("HAWK_ID", "")
+	t.Setenv("HAWK_KEY", "")
+	t.Setenv("HAWK_ALGORITHM", "")
+}
+
+func TestReadProfiles(t *testing.T) {
+	profiles, err := ReadProfiles(strings.NewReader(testProfiles))
+	if err != nil {
+		t.Fatalf("ReadProfiles failed: %s", err.Error())
+	}
+	if got, want := len(profiles), 2; got != want {
+		t.Fatalf("ReadProfiles failed:\n  got:  %d\n  want: %d", got, want)
+	}
+	p := profiles["default"]
+	if p.ID != "dh37fgj492je" || string(p.Key) != "werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn" || p.Algorithm != crypto.SHA256 {
+		t.Errorf("ReadProfiles failed: unexpected default profile: %+v", p)
+	}
+	p = profiles["staging"]
+	if p.Algorithm != crypto.SHA512 || p.BaseURL != "https://staging.example.com/" || p.Ext != "some-app-ext-data" {
+		t.Errorf("ReadProfiles failed: unexpected staging profile: %+v", p)
+	}
+
+	for _, bad := range []string{"id = x\n", "[a]\nid\n", "[a]\nuser = x\n", "[a]\nalgorithm = md4\n"} {
+		if _, err := ReadProfiles(strings.NewReader(bad)); err == nil {
+			t.Errorf("ReadProfiles failed: accepted %q", bad)
+		}
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		writeProfiles(t, testProfiles)
+		p, err := LoadProfile("")
+		if err != nil {
+			t.Fatalf("LoadProfile failed: %s", err.Error())
+		}
+		if got, want := p.ID, "dh37fgj492je"; got != want {
+			t.Errorf("LoadProfile failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("hawk-profile", func(t *testing.T) {
+		writeProfiles(t, testProfiles)
+		t.Setenv("HAWK_PROFILE", "staging")
+		p, err := LoadProfile("")
+		if err != nil {
+			t.Fatalf("LoadProfile failed: %s", err.Error())
+		}
+		if got, want := p.ID, "7gdh3j9aaee3"; got != want {
+			t.Errorf("LoadProfile failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("environment", func(t *testing.T) {
+		writeProfiles(t, testProfiles)
+		t.Setenv("HAWK_PROFILE", "staging")
+		t.Setenv("HAWK_KEY", "secret")
+		t.Setenv("HAWK_ALGORITHM", "sha384")
+		p, err := LoadProfile("")
+		if err != nil {
+			t.Fatalf("LoadProfile failed: %s", err.Error())
+		}
+		if p.ID != "7gdh3j9aaee3" || string(p.Key) != "secret" || p.Algorithm != crypto.SHA384 || p.Ext != "some-app-ext-data" {
+			t.Errorf("LoadProfile failed: environment not applied: %+v", p)
+		}
+	})
+	t.Run("environment-only", func(t *testing.T) {
+		writeProfiles(t, "")
+		t.Setenv("HAWK_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "missing"))
+		t.Setenv("HAWK_ID", "jdoe")
+		t.Setenv("HAWK_KEY", "secret")
+		p, err := LoadProfile("")
+		if err != nil {
+			t.Fatalf("LoadProfile failed: %s", err.Error())
+		}
+		if p.ID != "jdoe" || p.Algorithm != crypto.SHA256 {
+			t.Errorf("LoadProfile failed: unexpected profile: %+v", p)
+		}
+	})
+	t.Run("named", func(t *testing.T) {
+		writeProfiles(t, testProfiles)
+		t.Setenv("HAWK_KEY", "secret")
+		p, err := LoadProfile("default")
+		if err != nil {
+			t.Fatalf("LoadProfile failed: %s", err.Error())
+		}
+		if got, want := string(p.Key), "werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"; got != want {
+			t.Errorf("LoadProfile failed: environment overrode named profile:\n  got:  %s\n  want: %s", got, want)
+		}
+		if _, err := LoadProfile("missing"); err != ErrNoProfile {
+			t.Errorf("LoadProfile failed:\n  got:  %v\n  want: %v", err, ErrNoProfile)
+		}
+	})
+	t.Run("credential-process", func(t *testing.T) {
+		writeProfiles(t, "[vault]\ncredential_process = hawk-credentials --role api\n")
+		p, err := LoadProfile("vault")
+		if err != nil {
+			t.Fatalf("LoadProfile failed: %s", err.Error())
+		}
+		if got, want := p.CredentialProcess, "hawk-credentials --role api"; got != want {
+			t.Errorf("LoadProfile failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("incomplete", func(t *testing.T) {
+		writeProfiles(t, "[default]\nid = jdoe\n")
+		if _, err := LoadProfile(""); err == nil {
+			t.Errorf("LoadProfile failed: accepted profile without key")
+		}
+	})
+}
+
+func TestLoadClient(t *testing.T) {
+	writeProfiles(t, testProfiles)
+	c, err := LoadClient("staging")
+	if err != nil {
+		t.Fatalf("LoadClient failed: %s", err.Error())
+	}
+	req, err := c.NewRequest("GET", "/resource/1?b=1", nil, "", "")
+	if err != nil {
+		t.Fatalf("NewRequest failed: %s", err.Error())
+	}
+	if got, want := req.URL.String(), "https://staging.example.com/resource/1?b=1"; got != want {
+		t.Errorf("NewRequest failed:\n  got:  %s\n  want: %s", got, want)
+	}
+	auth := req.Header.Get("Authorization")
+	if !strings.Contains(auth, `id="7gdh3j9aaee3"`) || !strings.Contains(auth, `ext="some-app-ext-data"`) {
+		t.Errorf("NewRequest failed: unexpected Authorization: %s", auth)
+	}
+}
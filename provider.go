@@ -0,0 +1,146 @@
+package hawk
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// processRefresh is how long before expiry credentials from a process are
+// refreshed.
+const processRefresh = time.Minute
+
+// processInterval is the least time between runs of a credential process
+// while the credentials it gave are still valid.
+const processInterval = 10 * time.Second
+
+// CredentialProvider supplies the credentials a Client signs with. It must
+// be safe for concurrent use.
+type CredentialProvider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// ProcessProvider gets credentials from an external command, so that keys
+// need not be stored on disk. The command must print JSON to stdout:
+//
+//     {"id": "dh37fgj492je", "key": "werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn", "algorithm": "sha256", "expiration": "2018-11-25T10:00:00Z"}
+//
+// The algorithm defaults to sha256. The command is run when credentials
+// are first needed and the result is cached until a minute before
+// expiration, or for the lifetime of the provider if there is none. From
+// then on the command is run at most every ten seconds, and if it fails
+// the cached credentials are used until they expire.
+type ProcessProvider struct {
+	// Command is the command line, split on white space without shell
+	// quoting rules.
+	Command string
+	// ErrorLog logs failed refreshes while the cached credentials are
+	// still in use. If nil, logging goes to the log package's standard
+	// logger.
+	ErrorLog *log.Logger
+
+	mu      sync.Mutex
+	creds   Credentials
+	expires time.Time
+	cached  bool
+	ran     time.Time
+}
+
+// processOutput is the JSON printed by a credential process.
+type processOutput struct {
+	ID         string    `json:"id"`
+	Key        string    `json:"key"`
+	Algorithm  string    `json:"algorithm"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// Retrieve returns the cached credentials, running the command if they
+// are about to expire.
+func (p *ProcessProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if p.cached && (p.expires.IsZero() || now.Add(processRefresh).Before(p.expires)) {
+		return p.creds, nil
+	}
+	usable := p.cached && now.Before(p.expires)
+	if usable && now.Sub(p.ran) < processInterval {
+		return p.creds, nil
+	}
+	p.ran = now
+	creds, err := p.load(ctx)
+	if err != nil {
+		if usable {
+			p.logf("%s; using credentials until they expire at %s", err, p.expires.Format(time.RFC3339))
+			return p.creds, nil
+		}
+		return Credentials{}, err
+	}
+	p.creds, p.expires, p.cached = creds, creds.NotAfter, true
+	return p.creds, nil
+}
+
+// load runs the command and returns the credentials it printed.
+func (p *ProcessProvider) load(ctx context.Context) (Credentials, error) {
+	out, err := p.run(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	alg := crypto.SHA256
+	if out.Algorithm != "" {
+		if alg, err = LookupAlgorithm(out.Algorithm); err != nil {
+			return Credentials{}, fmt.Errorf("credential_process %q: %s: %q", p.Command, err, out.Algorithm)
+		}
+	}
+	if out.ID == "" || out.Key == "" {
+		return Credentials{}, fmt.Errorf("credential_process %q: No id and key in output", p.Command)
+	}
+	if !out.Expiration.IsZero() && !time.Now().Before(out.Expiration) {
+		return Credentials{}, fmt.Errorf("credential_process %q: Credentials expired at %s", p.Command, out.Expiration.Format(time.RFC3339))
+	}
+	return Credentials{ID: out.ID, Key: []byte(out.Key), Algorithm: alg, NotAfter: out.Expiration}, nil
+}
+
+func (p *ProcessProvider) logf(format string, args ...interface{}) {
+	if p.ErrorLog != nil {
+		p.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+func (p *ProcessProvider) run(ctx context.Context) (processOutput, error) {
+	args := strings.Fields(p.Command)
+	if len(args) == 0 {
+		return processOutput{}, errors.New("credential_process: No command")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return processOutput{}, fmt.Errorf("credential_process %q failed: %s: %s", p.Command, err, msg)
+		}
+		return processOutput{}, fmt.Errorf("credential_process %q failed: %s", p.Command, err)
+	}
+	var out processOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return processOutput{}, fmt.Errorf("credential_process %q: Invalid output: %s", p.Command, err)
+	}
+	return out, nil
+}
+
+// NewClientWithProvider creates a new Hawk client that signs with the
+// credentials supplied by p, retrieved anew for every request.
+func NewClientWithProvider(p CredentialProvider, nonceLength int) Client {
+	return Client{provider: p, NonceLength: nonceLength}
+}
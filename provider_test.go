@@ -0,0 +1,217 @@
+package hawk
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is run as a credential process by the tests below. It
+// prints HAWK_HELPER_OUTPUT, or fails if HAWK_HELPER_FAIL is set, and
+// counts its runs in the file HAWK_HELPER_COUNT.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("HAWK_HELPER_PROCESS") != "1" {
+		return
+	}
+	f, _ := os.OpenFile(os.Getenv("HAWK_HELPER_COUNT"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	f.Write([]byte("."))
+	f.Close()
+	if msg := os.Getenv("HAWK_HELPER_FAIL"); msg != "" {
+		fmt.Fprintln(os.Stderr, msg)
+		os.Exit(1)
+	}
+	fmt.Print(os.Getenv("HAWK_HELPER_OUTPUT"))
+	os.Exit(0)
+}
+
+// helperProvider returns a provider running TestHelperProcess with output,
+// and a function returning how many times it ran.
+func helperProvider(t *testing.T, output string) (*ProcessProvider, func() int) {
+	count := filepath.Join(t.TempDir(), "count")
+	t.Setenv("HAWK_HELPER_PROCESS", "1")
+	t.Setenv("HAWK_HELPER_COUNT", count)
+	t.Setenv("HAWK_HELPER_OUTPUT", output)
+	t.Setenv("HAWK_HELPER_FAIL", "")
+	p := &ProcessProvider{Command: os.Args[0] + " -test.run=^TestHelperProcess$"}
+	return p, func() int {
+		b, _ := ioutil.ReadFile(count)
+		return len(b)
+	}
+}
+
+func TestProcessProvider(t *testing.T) {
+	t.Run("cached", func(t *testing.T) {
+		p, runs := helperProvider(t, `{"id": "jdoe", "key": "Syp9393", "algorithm": "sha512"}`)
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c, err := p.Retrieve(context.Background())
+				if err != nil {
+					t.Errorf("Retrieve failed: %s", err.Error())
+				} else if c.ID != "jdoe" || string(c.Key) != "Syp9393" || c.Algorithm != crypto.SHA512 {
+					t.Errorf("Retrieve failed: unexpected credentials: %+v", c)
+				}
+			}()
+		}
+		wg.Wait()
+		if got, want := runs(), 1; got != want {
+			t.Errorf("Retrieve failed: process ran %d times, want %d", got, want)
+		}
+	})
+	t.Run("refresh", func(t *testing.T) {
+		exp := time.Now().Add(30 * time.Second).UTC().Format(time.RFC3339)
+		p, runs := helperProvider(t, `{"id": "jdoe", "key": "Syp9393", "expiration": "`+exp+`"}`)
+		for i := 0; i < 2; i++ {
+			c, err := p.Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("Retrieve failed: %s", err.Error())
+			}
+			if got, want := c.Algorithm, crypto.SHA256; got != want {
+				t.Errorf("Retrieve failed:\n  got:  %v\n  want: %v", got, want)
+			}
+		}
+		if got, want := runs(), 1; got != want {
+			t.Errorf("Retrieve failed: process ran %d times, want %d", got, want)
+		}
+		p.ran = time.Now().Add(-processInterval)
+		if _, err := p.Retrieve(context.Background()); err != nil {
+			t.Fatalf("Retrieve failed: %s", err.Error())
+		}
+		if got, want := runs(), 2; got != want {
+			t.Errorf("Retrieve failed: process ran %d times, want %d", got, want)
+		}
+	})
+	t.Run("refresh-failure", func(t *testing.T) {
+		exp := time.Now().Add(30 * time.Second).UTC().Format(time.RFC3339)
+		p, _ := helperProvider(t, `{"id": "jdoe", "key": "Syp9393", "expiration": "`+exp+`"}`)
+		var logged bytes.Buffer
+		p.ErrorLog = log.New(&logged, "", 0)
+		if _, err := p.Retrieve(context.Background()); err != nil {
+			t.Fatalf("Retrieve failed: %s", err.Error())
+		}
+		t.Setenv("HAWK_HELPER_FAIL", "vault sealed")
+		p.ran = time.Now().Add(-processInterval)
+		c, err := p.Retrieve(context.Background())
+		if err != nil || c.ID != "jdoe" {
+			t.Errorf("Retrieve failed: cached credentials not used: %v", err)
+		}
+		if !strings.Contains(logged.String(), "vault sealed") {
+			t.Errorf("Retrieve failed: refresh failure not logged: %q", logged.String())
+		}
+		p.expires = time.Now().Add(-time.Second)
+		if _, err := p.Retrieve(context.Background()); err == nil || !strings.Contains(err.Error(), "vault sealed") {
+			t.Errorf("Retrieve failed: unexpected error after expiry: %v", err)
+		}
+	})
+	t.Run("failure", func(t *testing.T) {
+		p, _ := helperProvider(t, "")
+		t.Setenv("HAWK_HELPER_FAIL", "vault sealed")
+		_, err := p.Retrieve(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "vault sealed") {
+			t.Errorf("Retrieve failed: unexpected error: %v", err)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		for _, out := range []string{
+			`not json`,
+			`{"id": "jdoe"}`,
+			`{"id": "jdoe", "key": "Syp9393", "algorithm": "md4"}`,
+			`{"id": "jdoe", "key": "Syp9393", "expiration": "2018-11-25T10:00:00Z"}`,
+		} {
+			p, _ := helperProvider(t, out)
+			if _, err := p.Retrieve(context.Background()); err == nil {
+				t.Errorf("Retrieve failed: accepted %s", out)
+			}
+		}
+	})
+	t.Run("no-command", func(t *testing.T) {
+		p := &ProcessProvider{}
+		if _, err := p.Retrieve(context.Background()); err == nil {
+			t.Errorf("Retrieve failed: accepted empty command")
+		}
+	})
+}
+
+func TestClientWithProvider(t *testing.T) {
+	p, runs := helperProvider(t, `{"id": "jdoe", "key": "Syp9393"}`)
+	c := NewClientWithProvider(p, 6)
+	v := &Verifier{Credentials: CredentialMap{"jdoe": {ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256}}}
+	for i := 0; i < 2; i++ {
+		req, err := c.NewRequest("GET", "http://example.com/resource", nil, "", "")
+		if err != nil {
+			t.Fatalf("NewRequest failed: %s", err.Error())
+		}
+		r := httptest.NewRequest(req.Method, req.URL.String(), nil)
+		r.Header = req.Header
+		if _, _, err := v.Verify(r); err != nil {
+			t.Errorf("Verify failed: %s", err.Error())
+		}
+	}
+	if got, want := runs(), 1; got != want {
+		t.Errorf("NewRequest failed: process ran %d times, want %d", got, want)
+	}
+
+	p.Command = ""
+	p.cached = false
+	if _, err := c.NewRequest("GET", "http://example.com/resource", nil, "", ""); err == nil {
+		t.Errorf("NewRequest failed: signed without credentials")
+	}
+}
+
+// ctxProvider is a CredentialProvider that only reports context errors.
+type ctxProvider struct{}
+
+func (ctxProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	if err := ctx.Err(); err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256}, nil
+}
+
+func TestClientContext(t *testing.T) {
+	c := NewClientWithProvider(ctxProvider{}, 6)
+	sign := map[string]func(ctx context.Context) error{
+		"AuthorizationContext": func(ctx context.Context) error {
+			_, err := c.AuthorizationContext(ctx, "GET", "example.com", "443", "/", "")
+			return err
+		},
+		"BewitContext": func(ctx context.Context) error {
+			_, err := c.BewitContext(ctx, "https://example.com/resource", time.Minute, "")
+			return err
+		},
+		"MessageContext": func(ctx context.Context) error {
+			_, err := c.MessageContext(ctx, "example.com", "443", []byte("hello"))
+			return err
+		},
+		"UpgradeHeaderContext": func(ctx context.Context) error {
+			_, err := c.UpgradeHeaderContext(ctx, "wss://example.com/socket", "")
+			return err
+		},
+		"SignFrameContext": func(ctx context.Context) error {
+			_, err := c.SignFrameContext(ctx, "example.com", "443", "handshake", []byte("hello"))
+			return err
+		},
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, f := range sign {
+		if err := f(context.Background()); err != nil {
+			t.Errorf("%s failed: %s", name, err.Error())
+		}
+		if err := f(canceled); err != context.Canceled {
+			t.Errorf("%s failed:\n  got:  %v\n  want: %v", name, err, context.Canceled)
+		}
+	}
+}
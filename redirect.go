@@ -0,0 +1,83 @@
+package hawk
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxRedirects is the number of redirects followed, as by http.Client.
+const maxRedirects = 10
+
+// errTooManyRedirects is returned by CheckRedirect after maxRedirects.
+var errTooManyRedirects = errors.New("stopped after 10 redirects")
+
+// CheckRedirect returns a redirect policy for http.Client that re-signs
+// redirected requests for their new location with a fresh nonce, keeping
+// the ext of the original request. The body is rewound by http.Client for
+// 307 and 308 redirects and hashed again.
+//
+// Only requests to the scheme, host and port of the original request and
+// to allowedHosts are signed; requests elsewhere are sent without
+// Authorization, as are those downgraded from https to http. Allowed hosts
+// starting with "*." match subdomains, and match the port of the original
+// request unless they name one:
+//
+//     c := &http.Client{CheckRedirect: hc.CheckRedirect("*.example.com", "api.example.net:8443")}
+//
+// The signature of the last request is the one ValidateResponse uses.
+func (c *Client) CheckRedirect(allowedHosts ...string) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return errTooManyRedirects
+		}
+		req.Header.Del("Authorization")
+		if !hostAllowed(req.URL, via[0].URL, allowedHosts) {
+			return nil
+		}
+		// Requests that were not signed carry no ext, so it is taken from
+		// the original.
+		attrs, _ := parseHeader(via[0].Header.Get("Authorization"))
+		h, s, err := c.sign(req, attrs["ext"])
+		if err != nil {
+			return err
+		}
+		c.setLast(h, s)
+		return nil
+	}
+}
+
+// hostAllowed reports whether credentials may be sent with a request to u
+// when the original request went to orig.
+func hostAllowed(u *url.URL, orig *url.URL, allowed []string) bool {
+	if orig.Scheme == "https" && u.Scheme != "https" {
+		return false
+	}
+	host, port := u.Hostname(), urlPort(u)
+	if u.Scheme == orig.Scheme && strings.EqualFold(host, orig.Hostname()) && port == urlPort(orig) {
+		return true
+	}
+	for _, a := range allowed {
+		p := urlPort(orig)
+		if h, ap, err := net.SplitHostPort(a); err == nil {
+			a, p = h, ap
+		}
+		if matchHost(a, host) && port == p {
+			return true
+		}
+	}
+	return false
+}
+
+// urlPort returns the port of u, defaulting it from the scheme.
+func urlPort(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
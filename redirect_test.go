@@ -0,0 +1,128 @@
+package hawk
+
+import (
+	"crypto"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// startRedirectServer verifies every request, redirects /old to the
+// Location in the target query parameter with the status in code, /loop to
+// itself, and echoes the body of other requests.
+func startRedirectServer(t *testing.T) *httptest.Server {
+	v := &Verifier{
+		Credentials: CredentialMap{"jdoe": {ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256}},
+		Nonces:      &MemoryNonceStore{}}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, creds, err := v.Verify(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/loop" {
+			http.Redirect(w, r, "/loop", http.StatusTemporaryRedirect)
+			return
+		}
+		if r.URL.Path == "/old" {
+			code := http.StatusTemporaryRedirect
+			if r.URL.Query().Get("code") == "303" {
+				code = http.StatusSeeOther
+			}
+			http.Redirect(w, r, r.URL.Query().Get("target"), code)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		body := []byte(r.Method + " " + h.reqExt + " " + string(b))
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Server-Authorization", h.GetServerAuthorization(creds.Key, "text/plain", body, ""))
+		w.Write(body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestCheckRedirect(t *testing.T) {
+	s := startRedirectServer(t)
+	localhost := strings.Replace(s.URL, "127.0.0.1", "localhost", 1)
+	cases := []struct {
+		name    string
+		target  string
+		code    string
+		allowed []string
+		status  int
+		body    string
+	}{
+		{"307", "/new", "307", nil, http.StatusOK, "POST some-ext Hello world!"},
+		{"303", "/new", "303", nil, http.StatusOK, "GET some-ext "},
+		{"foreign", localhost + "/new", "307", nil, http.StatusUnauthorized, ""},
+		{"allowed", localhost + "/new", "307", []string{"localhost"}, http.StatusOK, "POST some-ext Hello world!"},
+		{"too-many", "/loop", "307", nil, 0, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hc := NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+			client := &http.Client{CheckRedirect: hc.CheckRedirect(c.allowed...)}
+			req, _ := hc.NewRequest("POST", s.URL+"/old?code="+c.code+"&target="+c.target, strings.NewReader("Hello world!"), "text/plain", "some-ext")
+			resp, err := client.Do(req)
+			if c.status == 0 {
+				if err == nil {
+					t.Errorf("Do failed: redirect loop followed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Do failed: %s", err.Error())
+			}
+			if got, want := resp.StatusCode, c.status; got != want {
+				t.Fatalf("Do failed:\n  got:  %d\n  want: %d", got, want)
+			}
+			if c.status != http.StatusOK {
+				return
+			}
+			if !hc.ValidateResponse(*resp) {
+				t.Errorf("ValidateResponse failed: response to redirected request not valid")
+			}
+			req, _ = hc.NewRequest("POST", s.URL+"/old?code="+c.code+"&target="+c.target, strings.NewReader("Hello world!"), "text/plain", "some-ext")
+			resp, _ = client.Do(req)
+			b, _ := ioutil.ReadAll(resp.Body)
+			if got, want := string(b), c.body; got != want {
+				t.Errorf("Do failed:\n  got:  %s\n  want: %s", got, want)
+			}
+		})
+	}
+}
+
+func TestHostAllowed(t *testing.T) {
+	allowed := []string{"api.example.com", "*.example.net", "alt.example.com:8443"}
+	cases := []struct {
+		url  string
+		orig string
+		want bool
+	}{
+		{"https://origin.example.org/new", "https://origin.example.org", true},
+		{"https://ORIGIN.example.org:443", "https://origin.example.org", true},
+		{"https://origin.example.org:8443", "https://origin.example.org", false},
+		{"http://origin.example.org", "https://origin.example.org", false},
+		{"https://origin.example.org", "http://origin.example.org", false},
+		{"https://api.example.com", "https://origin.example.org", true},
+		{"https://api.example.com:8443", "https://origin.example.org", false},
+		{"http://api.example.com", "https://origin.example.org", false},
+		{"https://www.example.com", "https://origin.example.org", false},
+		{"https://a.b.example.net", "https://origin.example.org", true},
+		{"https://example.net", "https://origin.example.org", false},
+		{"https://evilexample.net", "https://origin.example.org", false},
+		{"https://alt.example.com:8443", "https://origin.example.org", true},
+		{"https://alt.example.com", "https://origin.example.org", false},
+	}
+	for _, c := range cases {
+		u, _ := url.Parse(c.url)
+		orig, _ := url.Parse(c.orig)
+		if got := hostAllowed(u, orig, allowed); got != c.want {
+			t.Errorf("hostAllowed failed for %s from %s:\n  got:  %t\n  want: %t", c.url, c.orig, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package hawk
+
+import (
+	"crypto/hmac"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewClientWithKeys creates a new Hawk client for key rotation, holding an
+// ordered list of credentials of which there must be at least one. It
+// signs with the first, and Do falls back to the others in order when a
+// request is rejected for its credentials. The credentials that are
+// accepted are used from then on, see Active. It panics if creds is empty.
+func NewClientWithKeys(creds []Credentials, nonceLength int) Client {
+	if len(creds) == 0 {
+		panic("hawk: NewClientWithKeys called without credentials")
+	}
+	keys := append([]Credentials(nil), creds...)
+	return Client{uid: keys[0].ID, key: keys[0].Key, hash: keys[0].Algorithm, signer: keys[0].Signer(), creds: keys[0], keys: keys, NonceLength: nonceLength}
+}
+
+// Active returns the credentials the client signs with. The key is left
+// out if the client signs with a Signer, and the credentials are zero if
+// it gets them from a CredentialProvider.
+func (c *Client) Active() Credentials {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.signer == nil {
+		return Credentials{}
+	}
+	creds := c.creds
+	creds.ID, creds.Key, creds.Algorithm = c.uid, c.key, c.hash
+	return creds
+}
+
+// use makes the i:th of the keys of c active.
+func (c *Client) use(i int) {
+	c.mu.Lock()
+	c.active = i
+	c.setCredentials(c.keys[i])
+	c.mu.Unlock()
+}
+
+// Do sends a request made by the client with hc, http.DefaultClient if nil.
+//
+// If the client was made with NewClientWithKeys and the server answers 401
+// with a Hawk error of a bad MAC or unknown credentials, the request is
+// signed again with the next credentials, keeping its ext, and resent
+// until one is accepted or all have been tried, skipping credentials that
+// are not valid. The last response is returned, and the active credentials
+// only change when a request is accepted. A request with a body is only
+// resent if it has GetBody, as those from NewRequest do.
+func (c *Client) Do(hc *http.Client, req *http.Request) (*http.Response, error) {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil || len(c.keys) < 2 || !credentialsRejected(resp) {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+	attrs, _ := parseHeader(req.Header.Get("Authorization"))
+	start := c.signedWith(req, attrs)
+	for i := 1; i < len(c.keys); i++ {
+		k := (start + i) % len(c.keys)
+		creds := c.keys[k]
+		if creds.Valid(time.Now()) != nil {
+			continue
+		}
+		next := req.Clone(req.Context())
+		if req.GetBody != nil {
+			if next.Body, err = req.GetBody(); err != nil {
+				return resp, nil
+			}
+		}
+		resp.Body.Close()
+		h, s, err := c.signWith(next, attrs["ext"], creds.ID, creds.Signer())
+		if err != nil {
+			return nil, err
+		}
+		c.setLast(h, s)
+		if resp, err = hc.Do(next); err != nil {
+			return nil, err
+		}
+		if !credentialsRejected(resp) {
+			c.use(k)
+			return resp, nil
+		}
+	}
+	return resp, nil
+}
+
+// signedWith returns the index of the key req was signed with, whose
+// Authorization has attrs, or of the active key if it was none of them.
+// Other requests may have changed the active key since req was signed.
+func (c *Client) signedWith(req *http.Request, attrs map[string]string) int {
+	ts, _ := strconv.ParseInt(attrs["ts"], 10, 64)
+	host, port := targetHostPort(req)
+	for i, creds := range c.keys {
+		if creds.ID != attrs["id"] {
+			continue
+		}
+		mac, err := signMAC(creds.Signer(), "header", ts, attrs["nonce"], req.Method, req.URL.RequestURI(), host, port, attrs["hash"], attrs["ext"])
+		if err == nil && hmac.Equal([]byte(mac), []byte(attrs["mac"])) {
+			return i
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active
+}
+
+// credentialsRejected reports whether resp is a Hawk 401 for a bad MAC or
+// unknown credentials. Hawk servers other than Verifier call the former
+// "Bad mac".
+func credentialsRejected(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	for _, v := range resp.Header.Values("WWW-Authenticate") {
+		attrs, err := parseHeader(v)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(attrs["error"]) {
+		case "invalid mac", "bad mac", "unknown credentials":
+			return true
+		}
+	}
+	return false
+}
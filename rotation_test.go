@@ -0,0 +1,158 @@
+package hawk
+
+import (
+	"crypto"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestClientWithKeys(t *testing.T) {
+	v := &Verifier{Credentials: CredentialMap{
+		"jdoe": {ID: "jdoe", Key: []byte("new"), Algorithm: crypto.SHA256},
+	}}
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, creds, err := v.Verify(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Hawk error="`+err.Error()+`"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		w.Header().Set("Server-Authorization", h.GetServerAuthorization(creds.Key, "text/plain", []byte("ok"), ""))
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	c := NewClientWithKeys([]Credentials{
+		{ID: "jdoe", Key: []byte("old"), Algorithm: crypto.SHA256},
+		{ID: "jroe", Key: []byte("new"), Algorithm: crypto.SHA256},
+		{ID: "jdoe", Key: []byte("new"), Algorithm: crypto.SHA256},
+	}, 6)
+	if got, want := string(c.Active().Key), "old"; got != want {
+		t.Errorf("Active failed:\n  got:  %s\n  want: %s", got, want)
+	}
+	for i := 0; i < 2; i++ {
+		req, err := c.NewRequest("POST", ts.URL+"/resource", strings.NewReader("payload"), "text/plain", "some-ext")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := c.Do(nil, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("Do failed:\n  got:  %d\n  want: %d", got, want)
+		}
+		if !c.ValidateResponse(*resp) {
+			t.Errorf("ValidateResponse failed")
+		}
+		resp.Body.Close()
+	}
+	if got, want := c.Active(), (Credentials{ID: "jdoe", Key: []byte("new")}); got.ID != want.ID || string(got.Key) != string(want.Key) {
+		t.Errorf("Active failed:\n  got:  %s %s\n  want: %s %s", got.ID, got.Key, want.ID, want.Key)
+	}
+	if got, want := strings.Join(bodies, ","), "payload,payload"; got != want {
+		t.Errorf("Do failed:\n  got:  %s\n  want: %s", got, want)
+	}
+
+	// Without accepted credentials the response to the last attempt is
+	// returned, which is rejected for an unknown id rather than the MAC of
+	// the first, and the active credentials are kept.
+	c = NewClientWithKeys([]Credentials{
+		{ID: "jdoe", Key: []byte("old"), Algorithm: crypto.SHA256},
+		{ID: "jroe", Key: []byte("new"), Algorithm: crypto.SHA256},
+	}, 6)
+	req, _ := c.NewRequest("GET", ts.URL+"/resource", nil, "", "")
+	resp, err := c.Do(nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, want := resp.Header.Get("WWW-Authenticate"), `Hawk error="Unknown credentials"`; got != want {
+		t.Errorf("Do failed:\n  got:  %s\n  want: %s", got, want)
+	}
+	if got, want := string(c.Active().Key), "old"; got != want {
+		t.Errorf("Active failed:\n  got:  %s\n  want: %s", got, want)
+	}
+}
+
+func TestClientWithKeysEmpty(t *testing.T) {
+	want := "hawk: NewClientWithKeys called without credentials"
+	defer func() {
+		if got := recover(); got != want {
+			t.Errorf("NewClientWithKeys failed:\n  got:  %v\n  want: %v", got, want)
+		}
+	}()
+	NewClientWithKeys(nil, 6)
+}
+
+func TestClientWithKeysConcurrent(t *testing.T) {
+	v := &Verifier{Credentials: CredentialMap{
+		"jdoe": {ID: "jdoe", Key: []byte("new"), Algorithm: crypto.SHA256},
+	}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := v.Verify(r); err != nil {
+			w.Header().Set("WWW-Authenticate", `Hawk error="`+err.Error()+`"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClientWithKeys([]Credentials{
+		{ID: "jdoe", Key: []byte("old"), Algorithm: crypto.SHA256},
+		{ID: "jdoe", Key: []byte("new"), Algorithm: crypto.SHA256},
+	}, 6)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := c.NewRequest("GET", ts.URL+"/resource", nil, "", "")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp, err := c.Do(nil, req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if got, want := resp.StatusCode, http.StatusOK; got != want {
+				t.Errorf("Do failed:\n  got:  %d\n  want: %d", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := string(c.Active().Key), "new"; got != want {
+		t.Errorf("Active failed:\n  got:  %s\n  want: %s", got, want)
+	}
+}
+
+func TestCredentialsRejected(t *testing.T) {
+	cases := []struct {
+		status int
+		header string
+		want   bool
+	}{
+		{401, `Hawk error="Invalid MAC"`, true},
+		{401, `Hawk error="Bad mac"`, true},
+		{401, `Hawk error="Unknown credentials"`, true},
+		{401, `Hawk ts="1353832234", tsm="x", error="Stale timestamp"`, false},
+		{401, `Basic realm="x"`, false},
+		{403, `Hawk error="Invalid MAC"`, false},
+	}
+	for _, c := range cases {
+		resp := &http.Response{StatusCode: c.status, Header: http.Header{"Www-Authenticate": {c.header}}}
+		if got := credentialsRejected(resp); got != c.want {
+			t.Errorf("credentialsRejected %d %s failed:\n  got:  %v\n  want: %v", c.status, c.header, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package hawk
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Route selects the Client that signs requests to a host, port and path.
+// Empty fields match anything. Host is matched without regard to case and
+// matches subdomains if it starts with "*.". The port defaults from the
+// scheme of the request.
+type Route struct {
+	Host       string
+	Port       string
+	PathPrefix string
+	Client     *Client
+}
+
+// matches reports whether r applies to a request to host, port and path.
+func (r Route) matches(host string, port string, path string) bool {
+	if r.Host != "" && !matchHost(r.Host, host) {
+		return false
+	}
+	if r.Port != "" && r.Port != port {
+		return false
+	}
+	return strings.HasPrefix(path, r.PathPrefix)
+}
+
+// RoutingTransport is an http.RoundTripper that signs requests with the
+// Client of the first of Routes that matches them, or Default if none does,
+// so that one http.Client can talk to services with credentials of their
+// own:
+//
+//     hc := &http.Client{Transport: &hawk.RoutingTransport{Routes: []hawk.Route{
+//         {Host: "api.example.com", Client: &exampleClient},
+//         {Host: "*.partner.net", PathPrefix: "/v2/", Client: &partnerClient},
+//     }}}
+//
+// Requests are signed as by Client.Sign, with the ext of the Client. A
+// request that neither a route nor Default matches is sent unsigned. The
+// request passed to RoundTrip is not modified.
+type RoutingTransport struct {
+	Routes  []Route
+	Default *Client
+	// Base sends the signed requests, http.DefaultTransport if nil.
+	Base http.RoundTripper
+}
+
+// RoundTrip signs req with the credentials routed to and sends it.
+func (t *RoutingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := t.client(req)
+	if c == nil {
+		return base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	_, _, err := c.sign(req, "")
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return base.RoundTrip(req)
+}
+
+// client returns the Client to sign req with, or nil.
+func (t *RoutingTransport) client(req *http.Request) *Client {
+	host, port := targetHostPort(req)
+	for _, r := range t.Routes {
+		if r.matches(host, port, req.URL.Path) {
+			return r.Client
+		}
+	}
+	return t.Default
+}
+
+// matchHost reports whether host matches pattern, which matches
+// subdomains if it starts with "*.".
+func matchHost(pattern string, host string) bool {
+	pattern, host = strings.ToLower(pattern), strings.ToLower(host)
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return host == pattern
+}
@@ -0,0 +1,103 @@
+package hawk
+
+import (
+	"crypto"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRoutingTransport(t *testing.T) {
+	v := &Verifier{Credentials: CredentialMap{
+		"partner": {ID: "partner", Key: []byte("partner-key"), Algorithm: crypto.SHA256},
+		"legacy":  {ID: "legacy", Key: []byte("legacy-key"), Algorithm: crypto.SHA512},
+		"default": {ID: "default", Key: []byte("default-key"), Algorithm: crypto.SHA256},
+	}}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.Write([]byte("unsigned"))
+			return
+		}
+		h, creds, err := v.Verify(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(creds.ID + " " + h.reqExt))
+	}
+	ts1 := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts1.Close()
+	ts2 := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts2.Close()
+	u1, _ := url.Parse(ts1.URL)
+	u2, _ := url.Parse(ts2.URL)
+
+	partner := NewClient("partner", []byte("partner-key"), crypto.SHA256, 6)
+	partner.Ext = "partner-ext"
+	legacy := NewClient("legacy", []byte("legacy-key"), crypto.SHA512, 6)
+	def := NewClient("default", []byte("default-key"), crypto.SHA256, 6)
+
+	cases := []struct {
+		transport *RoutingTransport
+		url       string
+		want      string
+	}{
+		{&RoutingTransport{Routes: []Route{
+			{Host: u1.Hostname(), Port: u1.Port(), PathPrefix: "/v1/", Client: &legacy},
+			{Host: u1.Hostname(), Port: u1.Port(), Client: &partner},
+		}, Default: &def}, ts1.URL + "/v1/resource", "legacy "},
+		{&RoutingTransport{Routes: []Route{
+			{Host: u1.Hostname(), Port: u1.Port(), PathPrefix: "/v1/", Client: &legacy},
+			{Host: u1.Hostname(), Port: u1.Port(), Client: &partner},
+		}, Default: &def}, ts1.URL + "/v2/resource", "partner partner-ext"},
+		{&RoutingTransport{Routes: []Route{
+			{Port: u1.Port(), Client: &partner},
+		}, Default: &def}, ts2.URL + "/resource", "default "},
+		{&RoutingTransport{Routes: []Route{
+			{Port: u1.Port(), Client: &partner},
+		}}, ts2.URL + "/resource", "unsigned"},
+		{&RoutingTransport{Routes: []Route{
+			{Host: "*.example.com", Client: &partner},
+			{Host: strings.ToUpper(u2.Hostname()), Port: u2.Port(), Client: &legacy},
+		}}, ts2.URL + "/resource", "legacy "},
+	}
+	for _, c := range cases {
+		hc := &http.Client{Transport: c.transport}
+		req, _ := http.NewRequest("POST", c.url, strings.NewReader("payload"))
+		req.Header.Set("Content-Type", "text/plain")
+		resp, err := hc.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 64)
+		n, _ := resp.Body.Read(b)
+		resp.Body.Close()
+		if got := string(b[:n]); got != c.want {
+			t.Errorf("RoundTrip %s failed:\n  got:  %s\n  want: %s", c.url, got, c.want)
+		}
+		if req.Header.Get("Authorization") != "" {
+			t.Errorf("RoundTrip %s failed: request modified", c.url)
+		}
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	cases := []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"example.com", "example.com", true},
+		{"Example.COM", "example.com", true},
+		{"example.com", "api.example.com", false},
+		{"*.example.com", "api.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "badexample.com", false},
+	}
+	for _, c := range cases {
+		if got := matchHost(c.pattern, c.host); got != c.want {
+			t.Errorf("matchHost %s %s failed:\n  got:  %v\n  want: %v", c.pattern, c.host, got, c.want)
+		}
+	}
+}
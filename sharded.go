@@ -0,0 +1,190 @@
+package hawk
+
+import (
+	"context"
+	"hash/maphash"
+	"math"
+	"sync"
+	"time"
+)
+
+// Defaults of ShardedNonceStore.
+const (
+	DefaultNonceShards = 64
+	// DefaultBloomCapacity is the number of nonces per window a
+	// ShardedNonceStore in Bloom filter mode is sized for by default.
+	DefaultBloomCapacity = 1 << 20
+)
+
+// bucketsPerWindow is the number of time buckets a nonce window is split
+// into. Whole buckets expire at once.
+const bucketsPerWindow = 4
+
+// ShardedNonceStore is a NonceStore for verifiers under heavy load. Nonces
+// are partitioned by a hash of the id and nonce into shards with a lock
+// each, so that the nonces of a busy id spread over all of them, and
+// within a shard into time buckets by request timestamp. A bucket is
+// dropped as a whole once its timestamps are older than Window, so memory
+// is bounded by the nonces of a little over two windows.
+//
+// With a FalsePositiveRate the buckets are Bloom filters instead of sets,
+// which bounds memory regardless of load at the cost of rejecting that
+// fraction of fresh nonces as replayed. The filters are sized for Capacity
+// nonces per Window.
+//
+// Requests with timestamps more than Window in the past or the future are
+// rejected with ErrStaleTimestamp. The zero value is ready to use.
+type ShardedNonceStore struct {
+	Window            time.Duration
+	Shards            int
+	FalsePositiveRate float64
+	Capacity          int
+
+	once   sync.Once
+	seed   maphash.Seed
+	shards []nonceShard
+	window int64
+	width  int64
+	bits   uint64
+	hashes int
+	now    func() time.Time
+}
+
+type nonceShard struct {
+	mu      sync.Mutex
+	buckets []nonceBucket
+}
+
+// nonceBucket holds the nonces of timestamps within one bucket width.
+type nonceBucket struct {
+	idx   int64
+	set   map[string]struct{}
+	bloom []uint64
+}
+
+func (s *ShardedNonceStore) init() {
+	s.window = int64(s.Window.Seconds())
+	if s.Window == 0 {
+		s.window = int64(DefaultNonceWindow.Seconds())
+	}
+	if s.window < 1 {
+		s.window = 1
+	}
+	n := s.Shards
+	if n <= 0 {
+		n = DefaultNonceShards
+	}
+	s.seed = maphash.MakeSeed()
+	s.width = s.window / bucketsPerWindow
+	if s.width < 1 {
+		s.width = 1
+	}
+	s.shards = make([]nonceShard, n)
+	// Live buckets span from a window in the past to a window in the
+	// future, plus partial buckets at both ends.
+	ring := 2*(s.window/s.width+1) + 1
+	for i := range s.shards {
+		s.shards[i].buckets = make([]nonceBucket, ring)
+		for j := range s.shards[i].buckets {
+			s.shards[i].buckets[j].idx = -1
+		}
+	}
+	if s.FalsePositiveRate > 0 {
+		capacity := s.Capacity
+		if capacity <= 0 {
+			capacity = DefaultBloomCapacity
+		}
+		perBucket := float64(capacity) / float64(n) / bucketsPerWindow
+		if perBucket < 64 {
+			perBucket = 64
+		}
+		m := math.Ceil(-perBucket * math.Log(s.FalsePositiveRate) / (math.Ln2 * math.Ln2))
+		s.bits = (uint64(m) + 63) &^ 63
+		s.hashes = int(math.Round(m / perBucket * math.Ln2))
+		if s.hashes < 1 {
+			s.hashes = 1
+		}
+	}
+}
+
+// Check records nonce for id and returns ErrReplayedNonce if it was already
+// used within the window.
+func (s *ShardedNonceStore) Check(ctx context.Context, id string, nonce string, ts int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.once.Do(s.init)
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	t := now().Unix()
+	if ts < t-s.window || ts > t+s.window {
+		return ErrStaleTimestamp
+	}
+
+	var h maphash.Hash
+	h.SetSeed(s.seed)
+	h.WriteString(id)
+	h.WriteByte(0)
+	h.WriteString(nonce)
+	sum := h.Sum64()
+	// The shard is picked by a remix of the sum, as the Bloom filter bits
+	// are derived from its low bits.
+	shard := &s.shards[mix64(sum)%uint64(len(s.shards))]
+
+	idx := ts / s.width
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	b := &shard.buckets[idx%int64(len(shard.buckets))]
+	if b.idx != idx {
+		b.idx = idx
+		b.set = nil
+		if b.bloom != nil {
+			for i := range b.bloom {
+				b.bloom[i] = 0
+			}
+		}
+	}
+	if s.FalsePositiveRate > 0 {
+		if b.bloom == nil {
+			b.bloom = make([]uint64, s.bits/64)
+		}
+		if !s.bloomAdd(b.bloom, sum) {
+			return ErrReplayedNonce
+		}
+		return nil
+	}
+	if b.set == nil {
+		b.set = make(map[string]struct{})
+	}
+	key := id + "\x00" + nonce
+	if _, ok := b.set[key]; ok {
+		return ErrReplayedNonce
+	}
+	b.set[key] = struct{}{}
+	return nil
+}
+
+// mix64 is the finalizer of SplitMix64.
+func mix64(x uint64) uint64 {
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// bloomAdd adds sum to the filter, reporting false if it was already
+// present. The bit positions are derived by double hashing.
+func (s *ShardedNonceStore) bloomAdd(filter []uint64, sum uint64) bool {
+	h1, h2 := sum, sum>>33|sum<<31|1
+	added := false
+	for i := 0; i < s.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % s.bits
+		w, m := bit/64, uint64(1)<<(bit%64)
+		if filter[w]&m == 0 {
+			filter[w] |= m
+			added = true
+		}
+	}
+	return added
+}
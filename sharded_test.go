@@ -0,0 +1,138 @@
+package hawk
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedNonceStore(t *testing.T) {
+	ctx := context.Background()
+	for _, rate := range []float64{0, 0.001} {
+		t.Run(fmt.Sprint("rate-", rate), func(t *testing.T) {
+			t.Run("replay", func(t *testing.T) {
+				s := &ShardedNonceStore{FalsePositiveRate: rate}
+				ts := time.Now().Unix()
+				if err := s.Check(ctx, "jdoe", "abc123", ts); err != nil {
+					t.Fatalf("Check failed: %s", err.Error())
+				}
+				if got, want := s.Check(ctx, "jdoe", "abc123", ts), ErrReplayedNonce; got != want {
+					t.Errorf("Check failed:\n  got:  %v\n  want: %v", got, want)
+				}
+				if err := s.Check(ctx, "jroe", "abc123", ts); err != nil {
+					t.Errorf("Check failed: %s", err.Error())
+				}
+			})
+			t.Run("stale", func(t *testing.T) {
+				s := &ShardedNonceStore{Window: time.Minute, FalsePositiveRate: rate}
+				for _, d := range []time.Duration{-2 * time.Minute, 2 * time.Minute} {
+					if got, want := s.Check(ctx, "jdoe", "abc123", time.Now().Add(d).Unix()), ErrStaleTimestamp; got != want {
+						t.Errorf("Check failed for %s:\n  got:  %v\n  want: %v", d, got, want)
+					}
+				}
+			})
+			t.Run("expire", func(t *testing.T) {
+				now := time.Now()
+				s := &ShardedNonceStore{Window: time.Minute, Shards: 1, FalsePositiveRate: rate}
+				s.now = func() time.Time { return now }
+				ts := now.Unix()
+				s.Check(ctx, "jdoe", "abc123", ts)
+				// A timestamp mapping to the same bucket slot a ring later
+				// replaces the bucket.
+				ring := int64(len(s.shards[0].buckets))
+				now = now.Add(time.Duration(ring*s.width) * time.Second)
+				if err := s.Check(ctx, "jdoe", "abc123", ts+ring*s.width); err != nil {
+					t.Errorf("Check failed: %s", err.Error())
+				}
+				if got, want := s.shards[0].buckets[(ts/s.width)%ring].idx, ts/s.width+ring; got != want {
+					t.Errorf("Check failed: bucket not replaced:\n  got:  %d\n  want: %d", got, want)
+				}
+			})
+			t.Run("canceled", func(t *testing.T) {
+				s := &ShardedNonceStore{FalsePositiveRate: rate}
+				cctx, cancel := context.WithCancel(ctx)
+				cancel()
+				if got, want := s.Check(cctx, "jdoe", "abc123", time.Now().Unix()), context.Canceled; got != want {
+					t.Errorf("Check failed:\n  got:  %v\n  want: %v", got, want)
+				}
+			})
+		})
+	}
+}
+
+func TestShardedNonceStoreConcurrent(t *testing.T) {
+	for _, rate := range []float64{0, 0.001} {
+		s := &ShardedNonceStore{FalsePositiveRate: rate, Capacity: 10000}
+		ts := time.Now().Unix()
+		var accepted int64
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 500; i++ {
+					if s.Check(context.Background(), fmt.Sprint("id", i%10), fmt.Sprint("nonce", i), ts) == nil {
+						atomic.AddInt64(&accepted, 1)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		// Every nonce is accepted once; in Bloom filter mode a few may be
+		// falsely rejected.
+		if accepted > 500 || rate == 0 && accepted != 500 || accepted < 490 {
+			t.Errorf("Check failed with rate %v: %d nonces accepted, want 500", rate, accepted)
+		}
+	}
+}
+
+func TestBloomFalsePositiveRate(t *testing.T) {
+	// The nonces of a single id must spread over the default shards for
+	// the filters to hold the capacity.
+	s := &ShardedNonceStore{FalsePositiveRate: 0.01, Capacity: 4 * 10000}
+	ts := time.Now().Unix()
+	rejected := 0
+	for i := 0; i < 10000; i++ {
+		if s.Check(context.Background(), "jdoe", fmt.Sprint("nonce", i), ts) == ErrReplayedNonce {
+			rejected++
+		}
+	}
+	// At capacity the rate of false rejections approaches the configured
+	// rate; below it, it stays lower.
+	if rejected > 100 {
+		t.Errorf("Check failed: %d of 10000 fresh nonces rejected, want at most 100", rejected)
+	}
+}
+
+func benchmarkNonceStore(b *testing.B, s NonceStore) {
+	ts := time.Now().Unix()
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = fmt.Sprint("id", i)
+	}
+	var n int64
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := atomic.AddInt64(&n, 1) << 32
+		for pb.Next() {
+			i++
+			s.Check(context.Background(), ids[i%1000], strconv.FormatInt(i, 36), ts)
+		}
+	})
+}
+
+func BenchmarkMemoryNonceStore(b *testing.B) {
+	benchmarkNonceStore(b, &MemoryNonceStore{})
+}
+
+func BenchmarkShardedNonceStore(b *testing.B) {
+	benchmarkNonceStore(b, &ShardedNonceStore{})
+}
+
+func BenchmarkShardedNonceStoreBloom(b *testing.B) {
+	benchmarkNonceStore(b, &ShardedNonceStore{FalsePositiveRate: 0.0001})
+}
@@ -0,0 +1,70 @@
+package hawk
+
+import (
+	"crypto"
+	"errors"
+)
+
+// Errors returned when signing.
+var (
+	ErrCannotFinalize    = errors.New("Missing request details or already finalized")
+	ErrAlgorithmMismatch = errors.New("Signer algorithm does not match")
+)
+
+// Signer calculates MACs over normalized Hawk strings, so that the key
+// need not be held by the code doing the signing. Sign returns the raw
+// MAC. A Signer must be safe for concurrent use.
+type Signer interface {
+	Algorithm() crypto.Hash
+	Sign(normalized []byte) ([]byte, error)
+}
+
+// keySigner signs with a key held in memory.
+type keySigner struct {
+	key  []byte
+	hash crypto.Hash
+}
+
+func (s keySigner) Algorithm() crypto.Hash {
+	return s.hash
+}
+
+func (s keySigner) Sign(normalized []byte) ([]byte, error) {
+	if !s.hash.Available() {
+		return nil, ErrUnknownAlgorithm
+	}
+	m, p := getMAC(s.hash, s.key)
+	m.Write(normalized)
+	mac := m.Sum(make([]byte, 0, s.hash.Size()))
+	putMAC(m, p)
+	return mac, nil
+}
+
+// Signer returns a Signer using the key and algorithm of c.
+func (c Credentials) Signer() Signer {
+	return keySigner{key: c.Key, hash: c.Algorithm}
+}
+
+// signMAC calculates the MAC of the given type with s, see normalizedString.
+func signMAC(s Signer, typ string, ts int64, n string, mtd string, uri string, hst string, p string, hsh string, ext string) (string, error) {
+	if ks, ok := s.(keySigner); ok {
+		if !ks.hash.Available() {
+			return "", ErrUnknownAlgorithm
+		}
+		return hashMAC(ks.hash, ks.key, typ, ts, n, mtd, uri, hst, p, hsh, ext), nil
+	}
+	bp := bufPool.Get().(*[]byte)
+	*bp = appendNormalized((*bp)[:0], typ, ts, n, mtd, uri, hst, p, hsh, ext)
+	mac, err := s.Sign(*bp)
+	bufPool.Put(bp)
+	if err != nil {
+		return "", err
+	}
+	return encodeSum(mac), nil
+}
+
+// NewClientWithSigner creates a new Hawk client that signs as uid with s
+// and never sees the key.
+func NewClientWithSigner(uid string, s Signer, nonceLength int) Client {
+	return Client{uid: uid, signer: s, hash: s.Algorithm(), NonceLength: nonceLength}
+}
@@ -0,0 +1,79 @@
+package hawk
+
+import (
+	"crypto"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingSigner signs with a key it holds and counts its signatures.
+type countingSigner struct {
+	Signer
+	n int
+}
+
+func (s *countingSigner) Sign(normalized []byte) ([]byte, error) {
+	s.n++
+	return s.Signer.Sign(normalized)
+}
+
+type failingSigner struct{}
+
+func (failingSigner) Algorithm() crypto.Hash        { return crypto.SHA256 }
+func (failingSigner) Sign(_ []byte) ([]byte, error) { return nil, errors.New("agent gone") }
+
+func TestFinalizeSigner(t *testing.T) {
+	hd := Details{
+		Algorithm: crypto.SHA256,
+		Host:      "example.com",
+		Port:      "8000",
+		URI:       "/resource/1?b=1&a=2",
+		Method:    "GET",
+		Timestamp: 1353832234,
+		Nonce:     "j4h3g2",
+		Ext:       "some-app-ext-data"}
+	creds := Credentials{Key: []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"), Algorithm: crypto.SHA256}
+
+	h, _ := hd.Create()
+	if err := h.FinalizeSigner(creds.Signer()); err != nil {
+		t.Fatalf("FinalizeSigner failed: %s", err.Error())
+	}
+	if got, want := h.GetReqMAC(), "6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="; got != want {
+		t.Errorf("FinalizeSigner failed:\n  got:  %s\n  want: %s", got, want)
+	}
+	if got, want := h.FinalizeSigner(creds.Signer()), ErrCannotFinalize; got != want {
+		t.Errorf("FinalizeSigner failed:\n  got:  %v\n  want: %v", got, want)
+	}
+
+	h, _ = hd.Create()
+	sha1 := Credentials{Key: creds.Key, Algorithm: crypto.SHA1}
+	if got, want := h.FinalizeSigner(sha1.Signer()), ErrAlgorithmMismatch; got != want {
+		t.Errorf("FinalizeSigner failed:\n  got:  %v\n  want: %v", got, want)
+	}
+	if err := h.FinalizeSigner(failingSigner{}); err == nil || err.Error() != "agent gone" {
+		t.Errorf("FinalizeSigner failed: unexpected error: %v", err)
+	}
+}
+
+func TestClientWithSigner(t *testing.T) {
+	s := &countingSigner{Signer: Credentials{Key: []byte("Syp9393"), Algorithm: crypto.SHA256}.Signer()}
+	c := NewClientWithSigner("jdoe", s, 6)
+	if _, err := c.Authorization("GET", "example.com", "443", "/", ""); err != nil {
+		t.Fatalf("Authorization failed: %s", err.Error())
+	}
+	if _, err := c.Bewit("https://example.com/resource", time.Minute, ""); err != nil {
+		t.Fatalf("Bewit failed: %s", err.Error())
+	}
+	if _, err := c.Message("example.com", "443", []byte("Hello")); err != nil {
+		t.Fatalf("Message failed: %s", err.Error())
+	}
+	if got, want := s.n, 3; got != want {
+		t.Errorf("Signer failed: signed %d times, want %d", got, want)
+	}
+
+	c = NewClientWithSigner("jdoe", failingSigner{}, 6)
+	if _, err := c.NewRequest("GET", "https://example.com/resource", nil, "", ""); err == nil {
+		t.Errorf("NewRequest failed: signed with failing signer")
+	}
+}
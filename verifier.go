@@ -0,0 +1,370 @@
+package hawk
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by Verifier.
+var (
+	ErrNoAuthorization    = errors.New("No Hawk authorization")
+	ErrMalformedHeader    = errors.New("Malformed Hawk header")
+	ErrUnknownCredentials = errors.New("Unknown credentials")
+	ErrInvalidMAC         = errors.New("Invalid MAC")
+	ErrInvalidPayloadHash = errors.New("Invalid payload hash")
+	ErrMissingPayloadHash = errors.New("Missing payload hash")
+	ErrPayloadTooLarge    = errors.New("Payload too large")
+	ErrNoCredentialStore  = errors.New("No credential store")
+	ErrStaleTimestamp     = errors.New("Stale timestamp")
+	ErrReplayedNonce      = errors.New("Replayed nonce")
+)
+
+// Credentials is a Hawk id together with its key and algorithm, and
+// optionally the lifetime of the key.
+type Credentials struct {
+	ID        string
+	Key       []byte
+	Algorithm crypto.Hash
+	// NotBefore and NotAfter, unless zero, bound the time the credentials
+	// are valid. NotAfter is the first instant they are not.
+	NotBefore time.Time
+	NotAfter  time.Time
+	// Revoked credentials are never valid.
+	Revoked bool
+}
+
+// CredentialStore looks up credentials by Hawk id. Lookup should return
+// ErrUnknownCredentials for ids it does not know, and give up when ctx is
+// done.
+type CredentialStore interface {
+	Lookup(ctx context.Context, id string) (Credentials, error)
+}
+
+// KeyRotationStore is a CredentialStore that may hold several keys for an
+// id while they are rotated. LookupAll returns the keys valid now, or if
+// none is, the one Lookup would return for the Verifier to reject. A
+// Verifier accepts a MAC by any of them.
+type KeyRotationStore interface {
+	CredentialStore
+	LookupAll(ctx context.Context, id string) ([]Credentials, error)
+}
+
+// NonceStore detects replayed requests. Check records the nonce used by id
+// at timestamp ts and returns ErrReplayedNonce if it has been used before.
+// It should give up when ctx is done.
+type NonceStore interface {
+	Check(ctx context.Context, id string, nonce string, ts int64) error
+}
+
+// CredentialMap is a CredentialStore backed by a map keyed on Hawk id.
+type CredentialMap map[string]Credentials
+
+// Lookup returns the credentials stored for id.
+func (m CredentialMap) Lookup(ctx context.Context, id string) (Credentials, error) {
+	c, ok := m[id]
+	if !ok {
+		return Credentials{}, ErrUnknownCredentials
+	}
+	return c, nil
+}
+
+// DefaultSkew is the clock skew allowed by a Verifier without a Skew of
+// its own.
+const DefaultSkew = time.Minute
+
+// Verifier authenticates Hawk requests on the server side.
+type Verifier struct {
+	Credentials CredentialStore
+	// Algorithms whitelists the algorithms credentials may use.
+	// DefaultAlgorithms is used if empty.
+	Algorithms []crypto.Hash
+	// Nonces, if set, is used to reject replayed requests.
+	Nonces NonceStore
+	// Skew is the allowed difference between the request timestamp and the
+	// server clock, DefaultSkew if zero.
+	Skew time.Duration
+	// Now returns the server time, time.Now if nil.
+	Now func() time.Time
+	// RequirePayloadHash rejects requests that do not include a hash.
+	RequirePayloadHash bool
+	// MaxPayloadSize, if set, is the largest body in bytes Verify reads to
+	// check a payload hash. Larger bodies are rejected with
+	// ErrPayloadTooLarge.
+	MaxPayloadSize int64
+}
+
+// Verify authenticates the Hawk Authorization header of r. If the header
+// carries a payload hash the body is read, checked and restored, unless it
+// is larger than MaxPayloadSize. The parsed
+// Hawk and the credentials it was verified with are returned. The context
+// of r is passed on to the credential and nonce stores.
+//
+// On ErrStaleTimestamp, whether from the Verifier or its NonceStore, the
+// credentials are returned along with the error, for use with
+// TimestampChallenge.
+func (v *Verifier) Verify(r *http.Request) (Hawk, Credentials, error) {
+	host, port := requestHostPort(r)
+	// RequestURI is the raw request target for server requests, but may be
+	// absolute when the request was sent to a proxy.
+	uri := r.RequestURI
+	if !strings.HasPrefix(uri, "/") {
+		uri = r.URL.RequestURI()
+	}
+	h, creds, err := v.VerifyHeaderContext(r.Context(), r.Header.Get("Authorization"), r.Method, uri, host, port)
+	if err != nil {
+		return Hawk{}, creds, err
+	}
+	if h.reqHash == "" {
+		if v.RequirePayloadHash {
+			return Hawk{}, Credentials{}, ErrMissingPayloadHash
+		}
+		return h, creds, nil
+	}
+	var content []byte
+	if r.Body != nil {
+		if v.MaxPayloadSize > 0 && r.ContentLength > v.MaxPayloadSize {
+			return Hawk{}, Credentials{}, ErrPayloadTooLarge
+		}
+		var body io.Reader = r.Body
+		if v.MaxPayloadSize > 0 {
+			body = io.LimitReader(r.Body, v.MaxPayloadSize+1)
+		}
+		content, err = ioutil.ReadAll(body)
+		r.Body.Close()
+		if err != nil {
+			return Hawk{}, Credentials{}, err
+		}
+		if v.MaxPayloadSize > 0 && int64(len(content)) > v.MaxPayloadSize {
+			return Hawk{}, Credentials{}, ErrPayloadTooLarge
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(content))
+	}
+	if !h.ValidatePayload(r.Header.Get("Content-Type"), content) {
+		return Hawk{}, Credentials{}, ErrInvalidPayloadHash
+	}
+	return h, creds, nil
+}
+
+// VerifyHeader authenticates a Hawk Authorization header value against the
+// given request method, URI, host and port. The payload hash, if any, is
+// not checked; use ValidatePayload on the returned Hawk for that.
+func (v *Verifier) VerifyHeader(auth string, method string, uri string, host string, port string) (Hawk, Credentials, error) {
+	return v.VerifyHeaderContext(context.Background(), auth, method, uri, host, port)
+}
+
+// VerifyHeaderContext is VerifyHeader with a context that is passed on to
+// the credential and nonce stores.
+//
+// Credentials that are expired, revoked or not yet valid are rejected with
+// ErrCredentialsExpired, ErrCredentialsRevoked or ErrCredentialsNotYetValid,
+// but only once the MAC has been verified, so that their state is not
+// revealed to anyone without the key.
+func (v *Verifier) VerifyHeaderContext(ctx context.Context, auth string, method string, uri string, host string, port string) (Hawk, Credentials, error) {
+	if v.Credentials == nil {
+		return Hawk{}, Credentials{}, ErrNoCredentialStore
+	}
+	attrs, err := parseHeader(auth)
+	if err != nil {
+		return Hawk{}, Credentials{}, err
+	}
+	id, mac := attrs["id"], attrs["mac"]
+	ts, err := strconv.ParseInt(attrs["ts"], 10, 64)
+	if id == "" || mac == "" || attrs["nonce"] == "" || err != nil {
+		return Hawk{}, Credentials{}, ErrMalformedHeader
+	}
+	h := Hawk{
+		host:      host,
+		port:      port,
+		uri:       uri,
+		method:    method,
+		timestamp: ts,
+		nonce:     attrs["nonce"],
+		reqHash:   attrs["hash"],
+		reqExt:    attrs["ext"]}
+	creds, err := v.lookup(ctx, id, mac, func(c Credentials) string {
+		return hashMAC(c.Algorithm, c.Key, "header", h.timestamp, h.nonce, h.method, h.uri, h.host, h.port, h.reqHash, h.reqExt)
+	})
+	if err != nil {
+		return Hawk{}, Credentials{}, err
+	}
+	h.algorithm = creds.Algorithm
+	if err := creds.Valid(v.now()); err != nil {
+		return Hawk{}, Credentials{}, err
+	}
+	if !v.fresh(ts) {
+		return Hawk{}, creds, ErrStaleTimestamp
+	}
+	if v.Nonces != nil {
+		if err := v.Nonces.Check(ctx, id, h.nonce, h.timestamp); err == ErrStaleTimestamp {
+			return Hawk{}, creds, err
+		} else if err != nil {
+			return Hawk{}, Credentials{}, err
+		}
+	}
+	h.reqMAC = mac
+	return h, creds, nil
+}
+
+// lookup returns the credentials of id whose key gives mac, as calculated
+// by macOf. The keys of a KeyRotationStore are tried in turn.
+func (v *Verifier) lookup(ctx context.Context, id string, mac string, macOf func(Credentials) string) (Credentials, error) {
+	ks, ok := v.Credentials.(KeyRotationStore)
+	if !ok {
+		creds, err := v.Credentials.Lookup(ctx, id)
+		if err != nil {
+			return Credentials{}, err
+		}
+		if err := allowedAlgorithm(creds.Algorithm, v.Algorithms); err != nil {
+			return Credentials{}, err
+		}
+		if !hmac.Equal([]byte(macOf(creds)), []byte(mac)) {
+			return Credentials{}, ErrInvalidMAC
+		}
+		return creds, nil
+	}
+	all, err := ks.LookupAll(ctx, id)
+	if err != nil {
+		return Credentials{}, err
+	}
+	err = ErrUnknownCredentials
+	for _, creds := range all {
+		if aerr := allowedAlgorithm(creds.Algorithm, v.Algorithms); aerr != nil {
+			if err == ErrUnknownCredentials {
+				err = aerr
+			}
+			continue
+		}
+		if hmac.Equal([]byte(macOf(creds)), []byte(mac)) {
+			return creds, nil
+		}
+		err = ErrInvalidMAC
+	}
+	return Credentials{}, err
+}
+
+// TimestampChallenge returns the value of a WWW-Authenticate header telling
+// the client the server time, in response to ErrStaleTimestamp. The
+// timestamp is authenticated with the client's credentials; an error is
+// returned if their algorithm is not registered and available.
+func (v *Verifier) TimestampChallenge(creds Credentials) (string, error) {
+	if AlgorithmName(creds.Algorithm) == "" || !creds.Algorithm.Available() {
+		return "", ErrUnknownAlgorithm
+	}
+	ts := v.now().Unix()
+	tsm := hashTimestamp(creds.Algorithm, creds.Key, ts)
+	return `Hawk ts="` + strconv.FormatInt(ts, 10) + `", tsm="` + tsm + `", error="` + ErrStaleTimestamp.Error() + `"`, nil
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now == nil {
+		return time.Now()
+	}
+	return v.Now()
+}
+
+func (v *Verifier) skew() time.Duration {
+	if v.Skew == 0 {
+		return DefaultSkew
+	}
+	return v.Skew
+}
+
+// fresh reports whether ts is within the allowed skew of the server clock.
+func (v *Verifier) fresh(ts int64) bool {
+	now := v.now().Unix()
+	skew := int64(v.skew().Seconds())
+	return ts >= now-skew && ts <= now+skew
+}
+
+// GetServerAuthorization returns the string to use in the
+// Server-Authorization HTTP header of the response to a verified request,
+// with a hash of the response payload.
+func (h *Hawk) GetServerAuthorization(key []byte, contentType string, content []byte, ext string) string {
+	hash := hashPayload(h.algorithm, contentType, content)
+	mac := hashMAC(h.algorithm, key, "response", h.timestamp, h.nonce, h.method, h.uri, h.host, h.port, hash, ext)
+	if ext != "" {
+		return `Hawk mac="` + mac + `", hash="` + hash + `", ext="` + ext + `"`
+	}
+	return `Hawk mac="` + mac + `", hash="` + hash + `"`
+}
+
+// GetServerAuthorizationWithoutHash is GetServerAuthorization without a
+// payload hash, for responses too large to be buffered. Only the response
+// headers, not the payload, are then authenticated.
+func (h *Hawk) GetServerAuthorizationWithoutHash(key []byte, ext string) string {
+	mac := hashMAC(h.algorithm, key, "response", h.timestamp, h.nonce, h.method, h.uri, h.host, h.port, "", ext)
+	if ext != "" {
+		return `Hawk mac="` + mac + `", ext="` + ext + `"`
+	}
+	return `Hawk mac="` + mac + `"`
+}
+
+// ValidatePayload reports whether the request payload hash received in the
+// Authorization header matches the given content type and content.
+func (h *Hawk) ValidatePayload(contentType string, content []byte) bool {
+	if h.reqHash == "" {
+		return false
+	}
+	calcHash := hashPayload(h.algorithm, contentType, content)
+	return hmac.Equal([]byte(calcHash), []byte(h.reqHash))
+}
+
+// parseHeader splits a Hawk header value into its attributes.
+func parseHeader(s string) (map[string]string, error) {
+	if len(s) < 5 || !strings.EqualFold(s[:5], "Hawk ") {
+		return nil, ErrNoAuthorization
+	}
+	// Equivalent to matching hawkPattern repeatedly, without the
+	// allocations of the regexp package.
+	attrs := make(map[string]string, 8)
+	s = s[5:]
+	for i := 0; i < len(s); {
+		if !isWordChar(s[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && isWordChar(s[j]) {
+			j++
+		}
+		if j+1 >= len(s) || s[j] != '=' || s[j+1] != '"' {
+			i = j
+			continue
+		}
+		k := strings.IndexByte(s[j+2:], '"')
+		if k == -1 {
+			break
+		}
+		attrs[s[i:j]] = s[j+2 : j+2+k]
+		i = j + 3 + k
+	}
+	return attrs, nil
+}
+
+// isWordChar reports whether c matches \w.
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// requestHostPort returns the host and port a server request was sent to,
+// defaulting the port from the scheme.
+func requestHostPort(r *http.Request) (string, string) {
+	host, port, err := net.SplitHostPort(r.Host)
+	if err == nil {
+		return host, port
+	}
+	if r.TLS != nil {
+		return r.Host, "443"
+	}
+	return r.Host, "80"
+}
@@ -0,0 +1,249 @@
+package hawk
+
+import (
+	"context"
+	"crypto"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifier(t *testing.T) {
+	key := []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")
+	v := &Verifier{Credentials: CredentialMap{
+		"dh37fgj492je": {ID: "dh37fgj492je", Key: key, Algorithm: crypto.SHA256},
+		"legacy":       {ID: "legacy", Key: key, Algorithm: crypto.SHA1},
+	}}
+	// The vectors are from the spec, so verify at the time they were made.
+	live := &Verifier{Credentials: v.Credentials}
+	v.Now = func() time.Time { return time.Unix(1353832234, 0) }
+	t.Run("ok", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com:8000/resource/1?b=1&a=2", nil)
+		req.Header.Set("Authorization", `Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", ext="some-app-ext-data", mac="6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="`)
+		_, creds, err := v.Verify(req)
+		if err != nil {
+			t.Fatalf("Verify failed: %s", err.Error())
+		}
+		if got, want := creds.ID, "dh37fgj492je"; got != want {
+			t.Errorf("Verify failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("payload", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "http://example.com:8000/resource/1?b=1&a=2", strings.NewReader("Thank you for flying Hawk"))
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Authorization", `Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", hash="Yi9LfIIFRtBEPt74PVmbTF/xVAwPn7ub15ePICfgnuY=", ext="some-app-ext-data", mac="aSe1DERmZuRl3pI36/9BdZmnErTw3sNzOOAUlfeKjVw="`)
+		if _, _, err := v.Verify(req); err != nil {
+			t.Fatalf("Verify failed: %s", err.Error())
+		}
+		b, _ := ioutil.ReadAll(req.Body)
+		if got, want := string(b), "Thank you for flying Hawk"; got != want {
+			t.Errorf("Verify failed: body not restored:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("payload-content-type-normalized", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "http://example.com:8000/resource/1?b=1&a=2", strings.NewReader("Thank you for flying Hawk"))
+		req.Header.Set("Content-Type", "Text/Plain; charset=utf-8")
+		req.Header.Set("Authorization", `Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", hash="Yi9LfIIFRtBEPt74PVmbTF/xVAwPn7ub15ePICfgnuY=", ext="some-app-ext-data", mac="aSe1DERmZuRl3pI36/9BdZmnErTw3sNzOOAUlfeKjVw="`)
+		if _, _, err := v.Verify(req); err != nil {
+			t.Errorf("Verify failed: %s", err.Error())
+		}
+	})
+	t.Run("altered-payload", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "http://example.com:8000/resource/1?b=1&a=2", strings.NewReader("Thank you for flying Hawk!"))
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Authorization", `Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", hash="Yi9LfIIFRtBEPt74PVmbTF/xVAwPn7ub15ePICfgnuY=", ext="some-app-ext-data", mac="aSe1DERmZuRl3pI36/9BdZmnErTw3sNzOOAUlfeKjVw="`)
+		if _, _, err := v.Verify(req); err != ErrInvalidPayloadHash {
+			t.Errorf("Verify failed:\n  got:  %v\n  want: %v", err, ErrInvalidPayloadHash)
+		}
+	})
+	t.Run("payload-too-large", func(t *testing.T) {
+		cases := []struct {
+			max           int64
+			contentLength int64
+			err           error
+		}{
+			{25, 25, nil},
+			{24, 25, ErrPayloadTooLarge},
+			{24, -1, ErrPayloadTooLarge},
+		}
+		for _, c := range cases {
+			v := &Verifier{Credentials: v.Credentials, Now: v.Now, MaxPayloadSize: c.max}
+			req := httptest.NewRequest("POST", "http://example.com:8000/resource/1?b=1&a=2", strings.NewReader("Thank you for flying Hawk"))
+			req.ContentLength = c.contentLength
+			req.Header.Set("Content-Type", "text/plain")
+			req.Header.Set("Authorization", `Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", hash="Yi9LfIIFRtBEPt74PVmbTF/xVAwPn7ub15ePICfgnuY=", ext="some-app-ext-data", mac="aSe1DERmZuRl3pI36/9BdZmnErTw3sNzOOAUlfeKjVw="`)
+			if _, _, err := v.Verify(req); err != c.err {
+				t.Errorf("Verify %d/%d failed:\n  got:  %v\n  want: %v", c.max, c.contentLength, err, c.err)
+			}
+		}
+	})
+	t.Run("altered-uri", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com:8000/resource/2?b=1&a=2", nil)
+		req.Header.Set("Authorization", `Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", ext="some-app-ext-data", mac="6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="`)
+		if _, _, err := v.Verify(req); err != ErrInvalidMAC {
+			t.Errorf("Verify failed:\n  got:  %v\n  want: %v", err, ErrInvalidMAC)
+		}
+	})
+	t.Run("unknown-id", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com:8000/resource/1?b=1&a=2", nil)
+		req.Header.Set("Authorization", `Hawk id="nobody", ts="1353832234", nonce="j4h3g2", mac="6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="`)
+		if _, _, err := v.Verify(req); err != ErrUnknownCredentials {
+			t.Errorf("Verify failed:\n  got:  %v\n  want: %v", err, ErrUnknownCredentials)
+		}
+	})
+	t.Run("algorithm-not-allowed", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com:8000/resource/1?b=1&a=2", nil)
+		req.Header.Set("Authorization", `Hawk id="legacy", ts="1353832234", nonce="j4h3g2", mac="6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="`)
+		if _, _, err := v.Verify(req); err != ErrAlgorithmNotAllowed {
+			t.Errorf("Verify failed:\n  got:  %v\n  want: %v", err, ErrAlgorithmNotAllowed)
+		}
+	})
+	t.Run("malformed", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com:8000/resource/1", nil)
+		req.Header.Set("Authorization", `Hawk id="dh37fgj492je", ts="soon", nonce="j4h3g2", mac="x"`)
+		if _, _, err := v.Verify(req); err != ErrMalformedHeader {
+			t.Errorf("Verify failed:\n  got:  %v\n  want: %v", err, ErrMalformedHeader)
+		}
+	})
+	t.Run("not-hawk", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com:8000/resource/1", nil)
+		req.Header.Set("Authorization", "Basic Zm9vOmJhcg==")
+		if _, _, err := v.Verify(req); err != ErrNoAuthorization {
+			t.Errorf("Verify failed:\n  got:  %v\n  want: %v", err, ErrNoAuthorization)
+		}
+	})
+	t.Run("client-roundtrip", func(t *testing.T) {
+		hc := NewClient("dh37fgj492je", key, crypto.SHA256, 6)
+		creq, err := hc.NewRequest("POST", "http://example.com/greeting", strings.NewReader("Hello world!"), "text/plain", "")
+		if err != nil {
+			t.Fatalf("NewRequest failed: %s", err.Error())
+		}
+		req := httptest.NewRequest("POST", "http://example.com/greeting", strings.NewReader("Hello world!"))
+		req.Header = creq.Header
+		if _, _, err := live.Verify(req); err != nil {
+			t.Errorf("Verify failed: %s", err.Error())
+		}
+	})
+	t.Run("client-authorization", func(t *testing.T) {
+		hc := NewClient("dh37fgj492je", key, crypto.SHA256, 6)
+		auth, err := hc.Authorization("POST", "example.com", "443", "/pkg.Service/Method", "")
+		if err != nil {
+			t.Fatalf("Authorization failed: %s", err.Error())
+		}
+		if _, _, err := live.VerifyHeader(auth, "POST", "/pkg.Service/Method", "example.com", "443"); err != nil {
+			t.Errorf("VerifyHeader failed: %s", err.Error())
+		}
+	})
+	t.Run("replay", func(t *testing.T) {
+		v := &Verifier{Credentials: v.Credentials, Nonces: &MemoryNonceStore{}}
+		hc := NewClient("dh37fgj492je", key, crypto.SHA256, 6)
+		creq, _ := hc.NewRequest("GET", "http://example.com/greeting", nil, "", "")
+		req := httptest.NewRequest("GET", "http://example.com/greeting", nil)
+		req.Header = creq.Header
+		if _, _, err := v.Verify(req); err != nil {
+			t.Fatalf("Verify failed: %s", err.Error())
+		}
+		if _, _, err := v.Verify(req); err != ErrReplayedNonce {
+			t.Errorf("Verify failed:\n  got:  %v\n  want: %v", err, ErrReplayedNonce)
+		}
+	})
+	t.Run("stale", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com:8000/resource/1?b=1&a=2", nil)
+		req.Header.Set("Authorization", `Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", ext="some-app-ext-data", mac="6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="`)
+		_, creds, err := live.Verify(req)
+		if err != ErrStaleTimestamp {
+			t.Fatalf("Verify failed:\n  got:  %v\n  want: %v", err, ErrStaleTimestamp)
+		}
+		if got, want := creds.ID, "dh37fgj492je"; got != want {
+			t.Errorf("Verify failed: no credentials with stale timestamp:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("context", func(t *testing.T) {
+		hc := NewClient("dh37fgj492je", key, crypto.SHA256, 6)
+		auth, _ := hc.Authorization("GET", "example.com", "443", "/", "")
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		v := &Verifier{Credentials: ctxStore{}}
+		if _, _, err := v.VerifyHeaderContext(ctx, auth, "GET", "/", "example.com", "443"); err != context.Canceled {
+			t.Errorf("VerifyHeaderContext failed:\n  got:  %v\n  want: %v", err, context.Canceled)
+		}
+	})
+}
+
+// ctxStore is a CredentialStore that only reports context errors.
+type ctxStore struct{}
+
+func (ctxStore) Lookup(ctx context.Context, id string) (Credentials, error) {
+	if err := ctx.Err(); err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{}, ErrUnknownCredentials
+}
+
+func TestTimestampChallenge(t *testing.T) {
+	creds := Credentials{ID: "123456", Key: []byte("2983d45yun89q"), Algorithm: crypto.SHA256}
+	v := &Verifier{Now: func() time.Time { return time.Unix(1365741469, 0) }}
+	challenge, err := v.TimestampChallenge(creds)
+	if err != nil {
+		t.Fatalf("TimestampChallenge failed: %s", err.Error())
+	}
+	attrs, err := parseHeader(challenge)
+	if err != nil {
+		t.Fatalf("TimestampChallenge failed: %s", err.Error())
+	}
+	if got, want := attrs["ts"], "1365741469"; got != want {
+		t.Errorf("TimestampChallenge failed:\n  got:  %s\n  want: %s", got, want)
+	}
+	if got, want := attrs["tsm"], hashTimestamp(crypto.SHA256, creds.Key, 1365741469); got != want {
+		t.Errorf("TimestampChallenge failed:\n  got:  %s\n  want: %s", got, want)
+	}
+	if got, want := attrs["error"], "Stale timestamp"; got != want {
+		t.Errorf("TimestampChallenge failed:\n  got:  %s\n  want: %s", got, want)
+	}
+	if _, err := v.TimestampChallenge(Credentials{}); err != ErrUnknownAlgorithm {
+		t.Errorf("TimestampChallenge failed:\n  got:  %v\n  want: %v", err, ErrUnknownAlgorithm)
+	}
+}
+
+func TestVerifierStaleNonce(t *testing.T) {
+	creds := Credentials{ID: "123456", Key: []byte("2983d45yun89q"), Algorithm: crypto.SHA256}
+	// The nonce store keeps nonces for a shorter time than the skew that
+	// is allowed, so it rejects timestamps the Verifier accepts.
+	v := &Verifier{Credentials: CredentialMap{creds.ID: creds}, Skew: 5 * time.Minute, Nonces: &MemoryNonceStore{}}
+	hd := Details{Algorithm: crypto.SHA256, Host: "example.com", Port: "80", URI: "/resource", Method: "GET", Timestamp: time.Now().Add(-3 * time.Minute).Unix()}
+	h, _ := hd.Create()
+	h.Finalize(creds.Key)
+	_, got, err := v.VerifyHeader(h.GetAuthorization(creds.ID), "GET", "/resource", "example.com", "80")
+	if err != ErrStaleTimestamp || got.ID != creds.ID {
+		t.Fatalf("VerifyHeader failed:\n  got:  %q %v\n  want: %q %v", got.ID, err, creds.ID, ErrStaleTimestamp)
+	}
+	if _, err := v.TimestampChallenge(got); err != nil {
+		t.Errorf("TimestampChallenge failed: %s", err.Error())
+	}
+}
+
+func TestServerAuthorization(t *testing.T) {
+	key := []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")
+	hd := Details{
+		Algorithm: crypto.SHA256,
+		Host:      "example.com",
+		Port:      "8000",
+		URI:       "/resource/1?b=1&a=2",
+		Method:    "GET",
+		Timestamp: 1353832234,
+		Nonce:     "j4h3g2",
+		Ext:       "some-app-ext-data"}
+	h, _ := hd.Create()
+	if got, want := h.GetServerAuthorization(key, "text/plain", []byte("some reply"), "response-specific"), `Hawk mac="ByjtDxJPtv2QW5OLXgTApOeVLJKKEanC9/nYp55SmIc=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="response-specific"`; got != want {
+		t.Errorf("GetServerAuthorization failed:\n  got:  %s\n  want: %s", got, want)
+	}
+	resp := http.Response{
+		Header: http.Header{"Server-Authorization": {h.GetServerAuthorizationWithoutHash(key, "response-specific")}},
+		Body:   ioutil.NopCloser(strings.NewReader("some reply"))}
+	if !h.ValidateResponse(key, resp) {
+		t.Errorf("GetServerAuthorizationWithoutHash failed: response not valid")
+	}
+}
@@ -0,0 +1,150 @@
+package hawk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrWrongID is returned by FrameVerifier for frames signed by an id other
+// than the one the connection was authenticated as.
+var ErrWrongID = errors.New("Frame sent with other credentials")
+
+// UpgradeHeader returns the headers for a WebSocket handshake to a ws:// or
+// wss:// url, with the upgrade GET request signed for Hawk. Pass them to
+// the dialer of the WebSocket library in use.
+func (c *Client) UpgradeHeader(url string, ext string) (http.Header, error) {
+	return c.UpgradeHeaderContext(context.Background(), url, ext)
+}
+
+// UpgradeHeaderContext is UpgradeHeader with a context that is passed on to
+// the Refresh function or CredentialProvider of the client.
+func (c *Client) UpgradeHeaderContext(ctx context.Context, url string, ext string) (http.Header, error) {
+	if strings.HasPrefix(url, "ws://") {
+		url = "http://" + url[5:]
+	} else if strings.HasPrefix(url, "wss://") {
+		url = "https://" + url[6:]
+	}
+	host, port, uri, err := parseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := c.AuthorizationContext(ctx, "GET", host, port, uri, ext)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("Authorization", auth)
+	return header, nil
+}
+
+// ConnectionNonce returns the nonce of the Hawk Authorization in the
+// headers of a WebSocket handshake, as returned by UpgradeHeader or
+// received by the server. Frames are signed and verified with it, so that
+// they are only accepted on the connection they were sent on.
+func ConnectionNonce(header http.Header) string {
+	attrs, _ := parseHeader(header.Get("Authorization"))
+	return attrs["nonce"]
+}
+
+// Frame is a WebSocket message carrying its own Hawk message authorization.
+type Frame struct {
+	Message
+	Payload []byte `json:"payload"`
+}
+
+// SignFrame encodes payload as a Frame authorized with creds for host and
+// port on the connection with the handshake nonce conn, see
+// ConnectionNonce.
+func SignFrame(creds Credentials, host string, port string, conn string, payload []byte) ([]byte, error) {
+	m, err := newMessage(creds.ID, creds.Signer(), host, port, conn, payload, 6)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Frame{Message: m, Payload: payload})
+}
+
+// SignFrame encodes payload as a Frame authorized for host and port on the
+// connection with the handshake nonce conn, see ConnectionNonce.
+func (c *Client) SignFrame(host string, port string, conn string, payload []byte) ([]byte, error) {
+	return c.SignFrameContext(context.Background(), host, port, conn, payload)
+}
+
+// SignFrameContext is SignFrame with a context that is passed on to the
+// Refresh function or CredentialProvider of the client.
+func (c *Client) SignFrameContext(ctx context.Context, host string, port string, conn string, payload []byte) ([]byte, error) {
+	uid, signer, err := c.signerFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newMessage(uid, signer, host, port, conn, payload, c.NonceLength)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Frame{Message: m, Payload: payload})
+}
+
+// FrameVerifier authenticates the frames received on a single WebSocket
+// connection. Every frame must be signed by the id the connection was
+// authenticated as and for the connection, be within the clock skew
+// allowed by Verifier and use a nonce not seen before on the connection.
+type FrameVerifier struct {
+	Verifier *Verifier
+	Host     string
+	Port     string
+	// ID is the Hawk id the connection was authenticated as during the
+	// handshake.
+	ID string
+	// Conn is the nonce of the handshake, see ConnectionNonce.
+	Conn string
+
+	mu     sync.Mutex
+	seen   map[string]int64
+	pruned int64
+}
+
+// Open verifies an encoded Frame and returns its payload.
+func (fv *FrameVerifier) Open(data []byte) ([]byte, error) {
+	return fv.OpenContext(context.Background(), data)
+}
+
+// OpenContext is Open with a context that is passed on to the credential
+// and nonce stores.
+func (fv *FrameVerifier) OpenContext(ctx context.Context, data []byte) ([]byte, error) {
+	var f Frame
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, ErrMalformedHeader
+	}
+	if f.ID != fv.ID {
+		return nil, ErrWrongID
+	}
+	if _, err := fv.Verifier.verifyMessage(ctx, fv.Host, fv.Port, fv.Conn, f.Payload, f.Message); err != nil {
+		return nil, err
+	}
+	now := fv.Verifier.now().Unix()
+	skew := int64(fv.Verifier.skew().Seconds())
+
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+	if fv.seen == nil {
+		fv.seen = make(map[string]int64)
+	}
+	if _, ok := fv.seen[f.Nonce]; ok {
+		return nil, ErrReplayedNonce
+	}
+	// Frames outside the skew window are rejected by the Verifier, so
+	// their nonces need not be remembered.
+	if now-fv.pruned >= skew {
+		for n, ts := range fv.seen {
+			if ts < now-skew {
+				delete(fv.seen, n)
+			}
+		}
+		fv.pruned = now
+	}
+	fv.seen[f.Nonce] = f.Ts
+	return f.Payload, nil
+}
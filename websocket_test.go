@@ -0,0 +1,144 @@
+package hawk
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUpgradeHeader(t *testing.T) {
+	v := &Verifier{Credentials: CredentialMap{
+		"jdoe": {ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256},
+	}}
+	hc := NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+	t.Run("wss", func(t *testing.T) {
+		header, err := hc.UpgradeHeader("wss://example.com/socket", "")
+		if err != nil {
+			t.Fatalf("UpgradeHeader failed: %s", err.Error())
+		}
+		req := httptest.NewRequest("GET", "https://example.com/socket", nil)
+		req.Header = header
+		h, _, err := v.Verify(req)
+		if err != nil {
+			t.Fatalf("Verify failed: %s", err.Error())
+		}
+		if got, want := ConnectionNonce(header), h.Artifacts().Nonce; got != want || got == "" {
+			t.Errorf("ConnectionNonce failed:\n  got:  %s\n  want: %s", got, want)
+		}
+	})
+	t.Run("ws-port", func(t *testing.T) {
+		header, err := hc.UpgradeHeader("ws://localhost:8000/socket?room=1", "")
+		if err != nil {
+			t.Fatalf("UpgradeHeader failed: %s", err.Error())
+		}
+		req := httptest.NewRequest("GET", "http://localhost:8000/socket?room=1", nil)
+		req.Header = header
+		if _, _, err := v.Verify(req); err != nil {
+			t.Errorf("Verify failed: %s", err.Error())
+		}
+	})
+	t.Run("broken-url", func(t *testing.T) {
+		if _, err := hc.UpgradeHeader("ftp://localhost/socket", ""); err == nil {
+			t.Errorf("UpgradeHeader failed: no error on unsupported scheme")
+		}
+	})
+}
+
+func TestFrames(t *testing.T) {
+	creds := Credentials{ID: "jdoe", Key: []byte("Syp9393"), Algorithm: crypto.SHA256}
+	v := &Verifier{Credentials: CredentialMap{"jdoe": creds, "mallory": {ID: "mallory", Key: []byte("x"), Algorithm: crypto.SHA256}}}
+	hc := NewClient("jdoe", []byte("Syp9393"), crypto.SHA256, 6)
+	newVerifier := func() *FrameVerifier {
+		return &FrameVerifier{Verifier: v, Host: "example.com", Port: "443", ID: "jdoe", Conn: "handshake"}
+	}
+	t.Run("ok", func(t *testing.T) {
+		fv := newVerifier()
+		for _, msg := range []string{"one", "two"} {
+			data, err := hc.SignFrame("example.com", "443", "handshake", []byte(msg))
+			if err != nil {
+				t.Fatalf("SignFrame failed: %s", err.Error())
+			}
+			payload, err := fv.Open(data)
+			if err != nil {
+				t.Fatalf("Open failed: %s", err.Error())
+			}
+			if got, want := string(payload), msg; got != want {
+				t.Errorf("Open failed:\n  got:  %s\n  want: %s", got, want)
+			}
+		}
+	})
+	t.Run("replay", func(t *testing.T) {
+		fv := newVerifier()
+		data, _ := SignFrame(creds, "example.com", "443", "handshake", []byte("once"))
+		if _, err := fv.Open(data); err != nil {
+			t.Fatalf("Open failed: %s", err.Error())
+		}
+		if _, err := fv.Open(data); err != ErrReplayedNonce {
+			t.Errorf("Open failed:\n  got:  %v\n  want: %v", err, ErrReplayedNonce)
+		}
+	})
+	t.Run("injected", func(t *testing.T) {
+		data, _ := SignFrame(creds, "example.com", "443", "handshake", []byte("hello"))
+		var f Frame
+		json.Unmarshal(data, &f)
+		f.Payload = []byte("rm -rf /")
+		data, _ = json.Marshal(f)
+		if _, err := newVerifier().Open(data); err != ErrInvalidPayloadHash {
+			t.Errorf("Open failed:\n  got:  %v\n  want: %v", err, ErrInvalidPayloadHash)
+		}
+	})
+	t.Run("other-id", func(t *testing.T) {
+		data, _ := SignFrame(Credentials{ID: "mallory", Key: []byte("x"), Algorithm: crypto.SHA256}, "example.com", "443", "handshake", []byte("hello"))
+		if _, err := newVerifier().Open(data); err != ErrWrongID {
+			t.Errorf("Open failed:\n  got:  %v\n  want: %v", err, ErrWrongID)
+		}
+	})
+	t.Run("prune", func(t *testing.T) {
+		fv := newVerifier()
+		fv.seen = map[string]int64{"old": time.Now().Add(-time.Hour).Unix()}
+		fv.pruned = time.Now().Unix()
+		data, _ := SignFrame(creds, "example.com", "443", "handshake", []byte("one"))
+		fv.Open(data)
+		if _, ok := fv.seen["old"]; !ok {
+			t.Errorf("Open failed: pruned before the interval")
+		}
+		fv.pruned = 0
+		data, _ = SignFrame(creds, "example.com", "443", "handshake", []byte("two"))
+		fv.Open(data)
+		if _, ok := fv.seen["old"]; ok || len(fv.seen) != 2 {
+			t.Errorf("Open failed:\n  got:  %v nonces\n  want: %v nonces", len(fv.seen), 2)
+		}
+	})
+	t.Run("other-connection", func(t *testing.T) {
+		data, _ := SignFrame(creds, "example.com", "443", "other", []byte("hello"))
+		if _, err := newVerifier().Open(data); err != ErrInvalidMAC {
+			t.Errorf("Open failed:\n  got:  %v\n  want: %v", err, ErrInvalidMAC)
+		}
+	})
+	t.Run("stale", func(t *testing.T) {
+		m, _ := NewMessage(creds, "example.com", "443", []byte("old"))
+		m.Ts = time.Now().Add(-time.Hour).Unix()
+		m.MAC = hashMAC(creds.Algorithm, creds.Key, "message", m.Ts, m.Nonce, "", "", "example.com", "443", m.Hash, "handshake")
+		data, _ := json.Marshal(Frame{Message: m, Payload: []byte("old")})
+		if _, err := newVerifier().Open(data); err != ErrStaleTimestamp {
+			t.Errorf("Open failed:\n  got:  %v\n  want: %v", err, ErrStaleTimestamp)
+		}
+	})
+	t.Run("context", func(t *testing.T) {
+		data, _ := SignFrame(creds, "example.com", "443", "handshake", []byte("hello"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		fv := &FrameVerifier{Verifier: &Verifier{Credentials: ctxStore{}}, Host: "example.com", Port: "443", ID: "jdoe", Conn: "handshake"}
+		if _, err := fv.OpenContext(ctx, data); err != context.Canceled {
+			t.Errorf("OpenContext failed:\n  got:  %v\n  want: %v", err, context.Canceled)
+		}
+	})
+	t.Run("garbage", func(t *testing.T) {
+		if _, err := newVerifier().Open([]byte("not json")); err != ErrMalformedHeader {
+			t.Errorf("Open failed:\n  got:  %v\n  want: %v", err, ErrMalformedHeader)
+		}
+	})
+}